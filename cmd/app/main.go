@@ -1,18 +1,61 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"os"
+	"time"
 	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
 	"truck-analytics-platform/internal/handlers"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/rpc"
+	"truck-analytics-platform/internal/scheduler"
+	"truck-analytics-platform/internal/tracing"
 )
 
 func main() {
-	_, err := db.Connect()
+	logging.Setup()
+
+	// Postgres may still be starting, give it a few minutes. Startup steps
+	// need it, so give up and let the orchestrator restart the container
+	// rather than serving without them.
+	connectCtx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	conn, err := db.ConnectWithRetry(connectCtx)
+	cancel()
 	if err != nil {
-		slog.Error(err.Error())
+		slog.Error("Can't connect to DB, exiting", "error", err)
+		os.Exit(1)
 	}
 	slog.Info("Connected to DB")
 
+	shutdown, err := tracing.Init(context.Background())
+	if err != nil {
+		slog.Error("Can't set up tracing", "error", err)
+	} else {
+		defer shutdown(context.Background())
+	}
+
+	if err := db.Migrate(context.Background(), conn); err != nil {
+		slog.Error("Can't apply migrations", "error", err)
+	}
+	if err := geo.Sync(context.Background(), conn); err != nil {
+		slog.Error("Can't sync region directory", "error", err)
+	}
+
+	// Reports read pre-aggregated data, make sure it matches the loaded tables
+	if err := db.RefreshAggregates(context.Background(), conn); err != nil {
+		slog.Error("Can't refresh aggregates", "error", err)
+	}
+
+	go scheduler.New(scheduler.ConfigFromEnv()).Start(context.Background(), conn)
+
+	go func() {
+		if err := rpc.Serve(rpc.ConfigFromEnv()); err != nil {
+			slog.Error("gRPC server stopped", "error", err)
+		}
+	}()
+
 	handlers.InitRouter()
 	slog.Info("Server started")
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/quality"
+)
+
+// Validates loaded registration data and prints the report as JSON. Exits
+// with 2 when -strict is set and errors were found.
+func main() {
+	strict := flag.Bool("strict", false, "exit with status 2 when the report has errors")
+	flag.Parse()
+
+	os.Exit(run(*strict))
+}
+
+// run returns the exit status, so deferred calls run before exiting
+func run(strict bool) int {
+	conn, err := db.Connect()
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	defer conn.Close()
+
+	report, err := quality.Check(context.Background(), conn)
+	if err != nil {
+		slog.Error("Can't check data quality", "error", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+
+	if strict && report.Errors > 0 {
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+)
+
+// Rebuilds report aggregates. Run it after loading new registration data.
+func main() {
+	os.Exit(run())
+}
+
+// run returns the exit status, so deferred calls run before exiting
+func run() int {
+	conn, err := db.Connect()
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	defer conn.Close()
+
+	// The aggregates carry region codes from the directory
+	if err := geo.Sync(context.Background(), conn); err != nil {
+		slog.Error("Can't sync region directory", "error", err)
+		return 1
+	}
+	if err := db.RefreshAggregates(context.Background(), conn); err != nil {
+		slog.Error("Can't refresh aggregates", "error", err)
+		return 1
+	}
+	return 0
+}
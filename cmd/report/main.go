@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/segments"
+)
+
+const (
+	formatTable = "table"
+	formatCSV   = "csv"
+	formatJSON  = "json"
+)
+
+// Runs a segment report against the configured database and prints it to
+// stdout. The period is -dataset, else the latest dataset of -year, else
+// the latest one loaded.
+//
+//	report -segment tractors4x2 -year 2024 -months 9 -format csv
+func main() {
+	segment := flag.String("segment", "", "segment to report: "+segmentNames())
+	dataset := flag.String("dataset", "", "registration table, e.g. truck_analytics_2024_01_09")
+	year := flag.Int("year", 0, "year of the period")
+	months := flag.Int("months", 0, "limit the report to the first months of the year")
+	brandList := flag.String("brands", "", "comma separated brands instead of the segment ones")
+	format := flag.String("format", formatTable, "output format: table, csv or json")
+	flag.Parse()
+
+	if _, ok := segments.Get(*segment); !ok {
+		fmt.Fprintf(os.Stderr, "unknown segment %q, expected one of %s\n", *segment, segmentNames())
+		os.Exit(2)
+	}
+	if *months < 0 || *months > 12 {
+		fmt.Fprintln(os.Stderr, "months must be between 0 and 12")
+		os.Exit(2)
+	}
+	if !slices.Contains([]string{formatTable, formatCSV, formatJSON}, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	os.Exit(run(*dataset, *year, *format, *brandList, reports.Query{Segment: *segment, Months: *months}))
+}
+
+// run returns the exit status, so deferred calls run before exiting
+func run(table string, year int, format, brandList string, q reports.Query) int {
+	conn, err := db.Connect()
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		slog.Error("Can't list datasets", "error", err)
+		return 1
+	}
+	d, err := db.SelectDataset(datasets, table, year, q.Months)
+	if err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	q.Dataset = d.Table
+	if brandList != "" {
+		if q.Brands, err = brands.Canonical(ctx, conn, strings.Split(brandList, ",")); err != nil {
+			slog.Error("Can't resolve brands", "error", err)
+			return 1
+		}
+	}
+
+	data, err := reports.Run(ctx, conn, q)
+	if err != nil {
+		slog.Error("Can't run report", "report", q.Name(), "error", err)
+		return 1
+	}
+
+	if err := write(format, q, data); err != nil {
+		slog.Error(err.Error())
+		return 1
+	}
+	return 0
+}
+
+func write(format string, q reports.Query, data map[string][]reports.TruckAnalytics) error {
+	if format == formatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Report reports.Query                       `json:"report"`
+			Title  string                              `json:"title"`
+			Data   map[string][]reports.TruckAnalytics `json:"data"`
+		}{q, q.Title(), data})
+	}
+
+	tables := []exports.Table{reports.Table(q, data)}
+	var out []byte
+	var err error
+	if format == formatCSV {
+		// The byte order mark is for Excel, scripts don't expect it
+		out, err = exports.CSV(tables)
+		out = bytes.TrimPrefix(out, []byte("\ufeff"))
+	} else {
+		fmt.Println(q.Title())
+		out, err = exports.Text(tables)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+func segmentNames() string {
+	names := make([]string, len(segments.All))
+	for i, s := range segments.All {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Keys look like tap_<64 hex chars>. Only their SHA-256 hash is stored,
+// the prefix is kept to tell keys apart in listings.
+const keyPrefix = "tap_"
+
+var ErrNotFound = errors.New("api key not found")
+
+// Permissions restrict what a key can query. Empty lists mean no restriction.
+type Permissions struct {
+	Admin    bool     `json:"admin"`
+	Segments []string `json:"segments"`
+	Years    []int    `json:"years"`
+	Brands   []string `json:"brands"`
+}
+
+type Key struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Prefix      string      `json:"prefix"`
+	Permissions Permissions `json:"permissions"`
+	CreatedAt   time.Time   `json:"created_at"`
+	RotatedAt   *time.Time  `json:"rotated_at"`
+	RevokedAt   *time.Time  `json:"revoked_at"`
+	LastUsedAt  *time.Time  `json:"last_used_at"`
+}
+
+// AllowsReport reports whether the key may query a segment in a year
+func (p Permissions) AllowsReport(segment string, year int) bool {
+	if len(p.Segments) > 0 && !slices.Contains(p.Segments, segment) {
+		return false
+	}
+	if len(p.Years) > 0 && !slices.Contains(p.Years, year) {
+		return false
+	}
+	return true
+}
+
+// AllowedBrands narrows report brand columns to the permitted ones
+func (p Permissions) AllowedBrands(brands []string) []string {
+	if len(p.Brands) == 0 {
+		return brands
+	}
+
+	var allowed []string
+	for _, b := range brands {
+		if slices.Contains(p.Brands, b) {
+			allowed = append(allowed, b)
+		}
+	}
+	return allowed
+}
+
+func generate() (secret string, hash []byte, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	secret = keyPrefix + hex.EncodeToString(buf)
+	return secret, hashKey(secret), nil
+}
+
+func hashKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+func displayPrefix(secret string) string {
+	return secret[:len(keyPrefix)+8]
+}
+
+const keyColumns = `id, name, prefix, admin, segments, years, brands, created_at, rotated_at, revoked_at, last_used_at`
+
+func scanKey(row pgx.Row) (Key, error) {
+	var k Key
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Permissions.Admin, &k.Permissions.Segments, &k.Permissions.Years, &k.Permissions.Brands,
+		&k.CreatedAt, &k.RotatedAt, &k.RevokedAt, &k.LastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return k, ErrNotFound
+	}
+	return k, err
+}
+
+// Create stores a new key and returns it with its secret, which is not
+// recoverable afterwards
+func Create(ctx context.Context, conn db.DB, name string, p Permissions) (Key, string, error) {
+	secret, hash, err := generate()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	k, err := scanKey(conn.QueryRow(ctx, `
+		INSERT INTO api_keys (name, prefix, key_hash, admin, segments, years, brands)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+keyColumns,
+		name, displayPrefix(secret), hash, p.Admin, nonNil(p.Segments), nonNil(p.Years), nonNil(p.Brands)))
+
+	return k, secret, err
+}
+
+// Rotate replaces the secret of an active key
+func Rotate(ctx context.Context, conn db.DB, id int64) (Key, string, error) {
+	secret, hash, err := generate()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	k, err := scanKey(conn.QueryRow(ctx, `
+		UPDATE api_keys SET prefix = $2, key_hash = $3, rotated_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING `+keyColumns,
+		id, displayPrefix(secret), hash))
+
+	return k, secret, err
+}
+
+// Revoke disables a key permanently
+func Revoke(ctx context.Context, conn db.DB, id int64) (Key, error) {
+	return scanKey(conn.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id = $1
+		RETURNING `+keyColumns, id))
+}
+
+func List(ctx context.Context, conn db.DB) ([]Key, error) {
+	rows, err := conn.Query(ctx, `SELECT `+keyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Lookup finds the active key matching a secret and records its use. The
+// use is written at most once a minute per key so busy clients don't turn
+// every request into a write.
+func Lookup(ctx context.Context, conn db.DB, secret string) (Key, error) {
+	return scanKey(conn.QueryRow(ctx, `
+		WITH found AS (
+			SELECT `+keyColumns+` FROM api_keys
+			WHERE key_hash = $1 AND revoked_at IS NULL
+		), used AS (
+			UPDATE api_keys SET last_used_at = now()
+			WHERE id IN (SELECT id FROM found)
+				AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+		)
+		SELECT `+keyColumns+` FROM found`, hashKey(secret)))
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
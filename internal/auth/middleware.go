@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Config struct {
+	Enabled bool
+	// AdminKey is accepted as an admin key without being stored, so the
+	// first keys can be created on a fresh database
+	AdminKey string
+}
+
+// ConfigFromEnv reads AUTH_DISABLED and ADMIN_API_KEY
+func ConfigFromEnv() Config {
+	return Config{
+		Enabled:  os.Getenv("AUTH_DISABLED") != "true",
+		AdminKey: os.Getenv("ADMIN_API_KEY"),
+	}
+}
+
+const contextKey = "apiKey"
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func abort(ctx *gin.Context, status int, message string) {
+	ctx.Error(errors.New(message))
+	ctx.AbortWithStatusJSON(status, errorResponse{Error: message, RequestID: logging.RequestID(ctx)})
+}
+
+// Middleware authenticates requests by the key in "Authorization: Bearer"
+// or X-API-Key and stores it in the context
+func Middleware(cfg Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !cfg.Enabled {
+			ctx.Next()
+			return
+		}
+
+		secret := keyFromRequest(ctx.Request)
+		if secret == "" {
+			abort(ctx, http.StatusUnauthorized, "API key required")
+			return
+		}
+
+		if cfg.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.AdminKey)) == 1 {
+			ctx.Set(contextKey, Key{Name: "bootstrap", Permissions: Permissions{Admin: true}})
+			ctx.Next()
+			return
+		}
+
+		conn, err := db.Open(ctx.Request.Context())
+		if err != nil {
+			slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+			abort(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+			return
+		}
+
+		key, err := Lookup(tracing.WithOperation(ctx.Request.Context(), "api key lookup"), conn, secret)
+		if errors.Is(err, ErrNotFound) {
+			abort(ctx, http.StatusUnauthorized, "Invalid API key")
+			return
+		}
+		if err != nil {
+			abort(ctx, http.StatusInternalServerError, "Failed to check API key: "+err.Error())
+			return
+		}
+
+		ctx.Set(contextKey, key)
+		ctx.Next()
+	}
+}
+
+// RequireReport rejects keys that aren't allowed to query a segment in a year
+func RequireReport(segment string, year int) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if key, ok := FromContext(ctx); ok && !key.Permissions.AllowsReport(segment, year) {
+			abort(ctx, http.StatusForbidden, "API key has no access to this report")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireAdmin only lets admin keys through. Without authentication there
+// is no admin, so admin routes are closed.
+func RequireAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if key, ok := FromContext(ctx); !ok || !key.Permissions.Admin {
+			abort(ctx, http.StatusForbidden, "Admin API key required")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// FromContext returns the key that authenticated the request
+func FromContext(ctx *gin.Context) (Key, bool) {
+	v, ok := ctx.Get(contextKey)
+	if !ok {
+		return Key{}, false
+	}
+	key, ok := v.(Key)
+	return key, ok
+}
+
+func keyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return ""
+}
@@ -0,0 +1,206 @@
+package brands
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/segments"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Raw "Brand" values are mapped to canonical names by the canonical_brand()
+// SQL function: a value matches a brand when it's the same up to case,
+// spaces and hyphens, or when it's one of the brand's aliases.
+
+var (
+	ErrNotFound = errors.New("brand not found")
+	ErrConflict = errors.New("brand or alias already exists")
+)
+
+type Brand struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// Unmapped is a raw brand value that doesn't match any canonical brand
+type Unmapped struct {
+	Value    string   `json:"value"`
+	Rows     int64    `json:"rows"`
+	Datasets []string `json:"datasets"`
+}
+
+// Key is the form aliases are stored and matched in, see brand_key()
+func Key(raw string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(strings.ReplaceAll(raw, "-", " ")), " "))
+}
+
+func List(ctx context.Context, conn db.DB) ([]Brand, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT b.name, COALESCE(array_agg(a.alias ORDER BY a.alias) FILTER (WHERE a.alias IS NOT NULL), '{}')
+		FROM brands b
+		LEFT JOIN brand_aliases a ON a.brand = b.name
+		GROUP BY b.name
+		ORDER BY b.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Brand{}
+	for rows.Next() {
+		var b Brand
+		if err := rows.Scan(&b.Name, &b.Aliases); err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// Create adds a canonical brand. Names are kept upper case like the report
+// brand columns.
+func Create(ctx context.Context, conn db.DB, name string) (Brand, error) {
+	name = Key(name)
+	_, err := conn.Exec(ctx, `INSERT INTO brands (name) VALUES ($1)`, name)
+	return Brand{Name: name, Aliases: []string{}}, constraintError(err)
+}
+
+// Delete removes a canonical brand with its aliases
+func Delete(ctx context.Context, conn db.DB, name string) error {
+	name = Key(name)
+	tag, err := conn.Exec(ctx, `DELETE FROM brands WHERE name = $1`, name)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// AddAlias maps another spelling to a canonical brand
+func AddAlias(ctx context.Context, conn db.DB, name, alias string) (Brand, error) {
+	name = Key(name)
+	_, err := conn.Exec(ctx, `INSERT INTO brand_aliases (alias, brand) VALUES ($1, $2)`, Key(alias), name)
+	if err := constraintError(err); err != nil {
+		return Brand{}, err
+	}
+	return get(ctx, conn, name)
+}
+
+func DeleteAlias(ctx context.Context, conn db.DB, name, alias string) (Brand, error) {
+	name = Key(name)
+	tag, err := conn.Exec(ctx, `DELETE FROM brand_aliases WHERE alias = $1 AND brand = $2`, Key(alias), name)
+	if err != nil {
+		return Brand{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return Brand{}, ErrNotFound
+	}
+	return get(ctx, conn, name)
+}
+
+func get(ctx context.Context, conn db.DB, name string) (Brand, error) {
+	b := Brand{Name: name}
+	err := conn.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(alias ORDER BY alias), '{}') FROM brand_aliases WHERE brand = $1
+	`, name).Scan(&b.Aliases)
+	return b, err
+}
+
+// Canonical maps raw brand values to canonical names, keeping unknown ones
+func Canonical(ctx context.Context, conn db.DB, raw []string) ([]string, error) {
+	result := make([]string, 0, len(raw))
+	rows, err := conn.Query(ctx, `SELECT canonical_brand(b) FROM unnest($1::text[]) WITH ORDINALITY AS t(b, i) ORDER BY i`, raw)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		result = append(result, name)
+	}
+	return result, rows.Err()
+}
+
+// Present lists the canonical brands found in the registration tables
+func Present(ctx context.Context, conn db.DB, datasets []db.Dataset) ([]string, error) {
+	if len(datasets) == 0 {
+		return []string{}, nil
+	}
+
+	var parts []string
+	for _, d := range datasets {
+		parts = append(parts, `SELECT DISTINCT canonical_brand("Brand") FROM `+pgx.Identifier{d.Table}.Sanitize()+` WHERE "Brand" IS NOT NULL`)
+	}
+
+	rows, err := conn.Query(ctx, strings.Join(parts, " UNION ")+` ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// UnmappedValues lists raw brand values of the registration tables that
+// don't resolve to a canonical brand, most frequent first
+func UnmappedValues(ctx context.Context, conn db.DB, datasets []db.Dataset) ([]Unmapped, error) {
+	if len(datasets) == 0 {
+		return []Unmapped{}, nil
+	}
+
+	var parts []string
+	for _, d := range datasets {
+		parts = append(parts, `SELECT "Brand"::text AS brand, `+segments.Literal(d.Table)+` AS dataset FROM `+
+			pgx.Identifier{d.Table}.Sanitize()+` WHERE "Brand" IS NOT NULL`)
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT brand, count(*), array_agg(DISTINCT dataset ORDER BY dataset)
+		FROM (`+strings.Join(parts, " UNION ALL ")+`) raw
+		WHERE NOT EXISTS (SELECT 1 FROM brands WHERE name = canonical_brand(brand))
+		GROUP BY brand
+		ORDER BY 2 DESC, 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Unmapped{}
+	for rows.Next() {
+		var u Unmapped
+		if err := rows.Scan(&u.Value, &u.Rows, &u.Datasets); err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
+
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return ErrConflict
+		case "23503":
+			return ErrNotFound
+		}
+	}
+	return err
+}
@@ -0,0 +1,256 @@
+package briefs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/charts"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/segments"
+)
+
+var (
+	ErrNoDataset = errors.New("no registration data for the period")
+	ErrNoAccess  = errors.New("API key has no access to any segment of the period")
+)
+
+// Brief is the market brief of the first Months months of Year, compared
+// with the same months of the year before when that data is loaded
+type Brief struct {
+	Year        int
+	Months      int
+	Dataset     string
+	Previous    string
+	GeneratedAt time.Time
+	Segments    []Segment
+}
+
+type Segment struct {
+	Segment   segments.Segment
+	Brands    []string
+	Districts []District
+	// Totals of each brand across the country
+	BrandTotals []Volume
+	Total       Volume
+	// Chart is a PNG of brand volumes per district
+	Chart []byte
+}
+
+// District is the row of a federal district, Brands in the order of Segment.Brands
+type District struct {
+	Name   string
+	Short  string
+	Brands []int
+	Total  Volume
+}
+
+// Volume is a named number of registrations with the same months of the
+// year before
+type Volume struct {
+	Name string
+	reports.Change
+}
+
+// Build runs the segment reports of a period that the permissions allow.
+// Months 0 means every month loaded for the year.
+func Build(ctx context.Context, conn db.DB, year, months int, p auth.Permissions) (Brief, error) {
+	brief := Brief{Year: year, Months: months, GeneratedAt: time.Now()}
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return brief, err
+	}
+	current, ok := db.PickDataset(datasets, year, months)
+	if !ok {
+		return brief, ErrNoDataset
+	}
+	if brief.Months == 0 || brief.Months > current.ToMonth {
+		brief.Months = current.ToMonth
+	}
+	brief.Dataset = current.Table
+	if previous, ok := (reports.Query{Dataset: current.Table, Months: brief.Months}).PreviousYear(datasets); ok {
+		brief.Previous = previous.Dataset
+	}
+
+	for _, s := range segments.All {
+		if !p.AllowsReport(s.Name, year) {
+			continue
+		}
+		brands := p.AllowedBrands(s.Brands)
+		if len(brands) == 0 {
+			continue
+		}
+
+		segment, err := buildSegment(ctx, conn, brief, s, brands)
+		if err != nil {
+			return brief, fmt.Errorf("%s: %w", s.Name, err)
+		}
+		brief.Segments = append(brief.Segments, segment)
+	}
+	if len(brief.Segments) == 0 {
+		return brief, ErrNoAccess
+	}
+
+	return brief, nil
+}
+
+func buildSegment(ctx context.Context, conn db.DB, brief Brief, s segments.Segment, brands []string) (Segment, error) {
+	result := Segment{Segment: s, Brands: brands, Total: Volume{Name: "Total"}}
+
+	current, err := districtTotals(ctx, conn, brief.Dataset, s.Name, brief.Months, brands)
+	if err != nil {
+		return result, err
+	}
+	var previous map[string]reports.TruckAnalytics
+	if brief.Previous != "" {
+		if previous, err = districtTotals(ctx, conn, brief.Previous, s.Name, brief.Months, brands); err != nil {
+			return result, err
+		}
+	}
+
+	result.BrandTotals = make([]Volume, len(brands))
+	for i, b := range brands {
+		result.BrandTotals[i].Name = b
+		if previous != nil {
+			result.BrandTotals[i].Previous = new(int)
+		}
+	}
+	if previous != nil {
+		result.Total.Previous = new(int)
+	}
+
+	for _, d := range geo.Districts {
+		row := District{Name: d.Name, Short: d.Short, Brands: make([]int, len(brands)), Total: Volume{Name: d.Name}}
+		if ta, ok := current[d.Code]; ok {
+			for i, b := range ta.Brands {
+				if b.Quantity != nil {
+					row.Brands[i] = *b.Quantity
+					result.BrandTotals[i].Current += *b.Quantity
+				}
+			}
+			row.Total.Current = ta.Total
+			result.Total.Current += ta.Total
+		}
+		if previous != nil {
+			row.Total.Previous = new(int)
+			if ta, ok := previous[d.Code]; ok {
+				for i, b := range ta.Brands {
+					if b.Quantity != nil {
+						*result.BrandTotals[i].Previous += *b.Quantity
+					}
+				}
+				*row.Total.Previous = ta.Total
+				*result.Total.Previous += ta.Total
+			}
+		}
+		result.Districts = append(result.Districts, row)
+	}
+
+	chart := charts.Chart{Kind: charts.Bars, Title: s.Name + ", registrations by federal district"}
+	for _, d := range result.Districts {
+		chart.Categories = append(chart.Categories, d.Short)
+	}
+	for i, b := range brands {
+		values := make([]float64, len(result.Districts))
+		for j, d := range result.Districts {
+			values[j] = float64(d.Brands[i])
+		}
+		chart.Series = append(chart.Series, charts.Series{Name: b, Values: values})
+	}
+	result.Chart, _, err = charts.Render(chart, charts.FormatPNG)
+
+	return result, err
+}
+
+// districtTotals runs a segment report and keeps the district total rows,
+// keyed by district code
+func districtTotals(ctx context.Context, conn db.DB, dataset, segment string, months int, brands []string) (map[string]reports.TruckAnalytics, error) {
+	q := reports.Query{Dataset: dataset, Segment: segment, Months: months, Brands: brands}
+	data, err := reports.Run(ctx, conn, q)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := map[string]reports.TruckAnalytics{}
+	for district, rows := range data {
+		d, ok := geo.FindDistrict(district)
+		if !ok {
+			continue
+		}
+		for _, row := range rows {
+			if row.RegionName == district {
+				totals[d.Code] = row
+			}
+		}
+	}
+	return totals, nil
+}
+
+// SummaryChart compares segment totals with the year before
+func (b Brief) SummaryChart() ([]byte, error) {
+	chart := charts.Chart{Kind: charts.Bars, Title: "Registrations by segment"}
+	current := charts.Series{Name: fmt.Sprint(b.Year)}
+	previous := charts.Series{Name: fmt.Sprint(b.Year - 1)}
+	for _, s := range b.Segments {
+		chart.Categories = append(chart.Categories, s.Segment.Name)
+		current.Values = append(current.Values, float64(s.Total.Current))
+		if s.Total.Previous != nil {
+			previous.Values = append(previous.Values, float64(*s.Total.Previous))
+		}
+	}
+	if b.Previous != "" {
+		chart.Series = append(chart.Series, previous)
+	}
+	chart.Series = append(chart.Series, current)
+
+	image, _, err := charts.Render(chart, charts.FormatPNG)
+	return image, err
+}
+
+// Tables lays the brief out for CSV and XLSX exports: the summary and the
+// district table of every segment
+func (b Brief) Tables() []exports.Table {
+	previous := fmt.Sprint(b.Year - 1)
+	summary := exports.Table{Name: "summary", Header: []string{"segment", fmt.Sprint(b.Year), previous, "yoy"}}
+	tables := []exports.Table{summary}
+
+	for _, s := range b.Segments {
+		tables[0].Rows = append(tables[0].Rows, volumeRow(s.Segment.Name, s.Total))
+
+		t := exports.Table{Name: s.Segment.Name, Header: []string{"federal_district"}}
+		for _, brand := range s.Brands {
+			t.Header = append(t.Header, strings.ToLower(brand))
+		}
+		t.Header = append(t.Header, "total", previous, "yoy")
+		for _, d := range s.Districts {
+			row := []any{d.Name}
+			for _, v := range d.Brands {
+				row = append(row, v)
+			}
+			t.Rows = append(t.Rows, append(row, volumeRow("", d.Total)[1:]...))
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// volumeRow is a name followed by the current, previous and change columns,
+// empty when there is nothing to compare with
+func volumeRow(name string, v Volume) []any {
+	row := []any{name, v.Current, nil, nil}
+	if v.Previous != nil {
+		row[2] = *v.Previous
+	}
+	if d, ok := v.Delta(); ok {
+		row[3] = math.Round(d*1000) / 1000
+	}
+	return row
+}
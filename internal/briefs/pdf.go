@@ -0,0 +1,197 @@
+package briefs
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+var monthNames = []string{"January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December"}
+
+const (
+	font        = "Go"
+	pageWidth   = 297
+	margin      = 15
+	lineHeight  = 7
+	headerColor = 230
+)
+
+// Period names the months of a year, e.g. "January–September 2024"
+func Period(year, months int) string {
+	if months <= 1 {
+		return fmt.Sprintf("%s %d", monthNames[0], year)
+	}
+	return fmt.Sprintf("%s–%s %d", monthNames[0], monthNames[min(months, 12)-1], year)
+}
+
+// PDF renders the brief: a cover page, the national summary and a page per
+// segment with its district table, chart and brand totals
+func (b Brief) PDF() ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	// The Go fonts cover Cyrillic district and region names
+	pdf.AddUTF8FontFromBytes(font, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(font, "B", gobold.TTF)
+	pdf.SetTitle("Truck market brief, "+Period(b.Year, b.Months), true)
+
+	generated := b.GeneratedAt.UTC().Format("2006-01-02 15:04 UTC")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.SetFont(font, "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		half := float64(pageWidth-2*margin) / 2
+		pdf.CellFormat(half, 5, "Generated "+generated, "", 0, "L", false, 0, "")
+		pdf.CellFormat(half, 5, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	b.cover(pdf, generated)
+	if err := b.summary(pdf); err != nil {
+		return nil, err
+	}
+	for i, s := range b.Segments {
+		b.segment(pdf, i, s)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (b Brief) cover(pdf *gofpdf.Fpdf, generated string) {
+	pdf.AddPage()
+	pdf.SetY(60)
+	pdf.SetFont(font, "B", 32)
+	pdf.CellFormat(0, 16, "Truck market brief", "", 1, "C", false, 0, "")
+	pdf.SetFont(font, "", 20)
+	pdf.CellFormat(0, 12, Period(b.Year, b.Months), "", 1, "C", false, 0, "")
+
+	pdf.Ln(10)
+	pdf.SetFont(font, "", 12)
+	if b.Previous != "" {
+		pdf.CellFormat(0, lineHeight, "Compared with "+Period(b.Year-1, b.Months), "", 1, "C", false, 0, "")
+	} else {
+		pdf.CellFormat(0, lineHeight, "No data loaded for the year before", "", 1, "C", false, 0, "")
+	}
+	names := make([]string, len(b.Segments))
+	for i, s := range b.Segments {
+		names[i] = s.Segment.Name
+	}
+	pdf.CellFormat(0, lineHeight, "Segments: "+strings.Join(names, ", "), "", 1, "C", false, 0, "")
+	pdf.CellFormat(0, lineHeight, "Source: "+b.Dataset, "", 1, "C", false, 0, "")
+
+	pdf.Ln(10)
+	pdf.SetFont(font, "", 10)
+	pdf.CellFormat(0, lineHeight, "Generated "+generated, "", 1, "C", false, 0, "")
+}
+
+func (b Brief) summary(pdf *gofpdf.Fpdf) error {
+	pdf.AddPage()
+	heading(pdf, "National summary")
+
+	widths := []float64{70, 40, 40, 30}
+	header := []string{"Segment", fmt.Sprint(b.Year), fmt.Sprint(b.Year - 1), "YoY"}
+	tableRow(pdf, widths, header, true)
+	for _, s := range b.Segments {
+		tableRow(pdf, widths, append([]string{s.Segment.Name}, volumeCells(s.Total)...), false)
+	}
+
+	chart, err := b.SummaryChart()
+	if err != nil {
+		return err
+	}
+	pdf.Ln(6)
+	image(pdf, "summary", chart, 180)
+
+	return pdf.Error()
+}
+
+func (b Brief) segment(pdf *gofpdf.Fpdf, i int, s Segment) {
+	pdf.AddPage()
+	heading(pdf, s.Segment.Name)
+	pdf.SetFont(font, "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%s, %s, %s = %v", s.Segment.WheelFormula, s.Segment.BodyType, s.Segment.Mass.Column, s.Segment.Mass.Value),
+		"", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	// Districts by brand, the district total and its change
+	brandWidth := (pageWidth - 2*margin - 60 - 3*24) / float64(max(len(s.Brands), 1))
+	widths := []float64{60}
+	header := []string{"Federal district"}
+	for _, brand := range s.Brands {
+		widths = append(widths, brandWidth)
+		header = append(header, brand)
+	}
+	widths = append(widths, 24, 24, 24)
+	header = append(header, "Total", fmt.Sprint(b.Year-1), "YoY")
+
+	tableRow(pdf, widths, header, true)
+	for _, d := range s.Districts {
+		cells := []string{d.Name}
+		for _, v := range d.Brands {
+			cells = append(cells, fmt.Sprint(v))
+		}
+		tableRow(pdf, widths, append(cells, volumeCells(d.Total)...), false)
+	}
+	cells := []string{"Russia"}
+	for _, v := range s.BrandTotals {
+		cells = append(cells, fmt.Sprint(v.Current))
+	}
+	tableRow(pdf, widths, append(cells, volumeCells(s.Total)...), true)
+
+	pdf.AddPage()
+	heading(pdf, s.Segment.Name+", brands")
+	tableRow(pdf, []float64{60, 30, 30, 24}, []string{"Brand", fmt.Sprint(b.Year), fmt.Sprint(b.Year - 1), "YoY"}, true)
+	for _, v := range s.BrandTotals {
+		tableRow(pdf, []float64{60, 30, 30, 24}, append([]string{v.Name}, volumeCells(v)...), false)
+	}
+	pdf.Ln(6)
+	image(pdf, fmt.Sprintf("segment%d", i), s.Chart, 200)
+}
+
+func heading(pdf *gofpdf.Fpdf, text string) {
+	pdf.SetFont(font, "B", 18)
+	pdf.CellFormat(0, 12, text, "", 1, "L", false, 0, "")
+}
+
+func tableRow(pdf *gofpdf.Fpdf, widths []float64, cells []string, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+		pdf.SetFillColor(headerColor, headerColor, headerColor)
+	}
+	pdf.SetFont(font, style, 9)
+	for i, c := range cells {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], lineHeight, c, "1", 0, align, bold, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func image(pdf *gofpdf.Fpdf, name string, png []byte, width float64) {
+	pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+	pdf.ImageOptions(name, (pageWidth-width)/2, pdf.GetY(), width, 0, true, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+}
+
+// volumeCells renders current, previous and change columns
+func volumeCells(v Volume) []string {
+	previous, delta := "—", "—"
+	if v.Previous != nil {
+		previous = fmt.Sprint(*v.Previous)
+	}
+	if d, ok := v.Delta(); ok {
+		delta = fmt.Sprintf("%+.1f%%", d*100)
+	}
+	return []string{fmt.Sprint(v.Current), previous, delta}
+}
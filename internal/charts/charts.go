@@ -0,0 +1,216 @@
+package charts
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+type Kind int
+
+const (
+	// Bars draws one bar per series in every category
+	Bars Kind = iota
+	// Stacked draws one bar per category split by the share of each series
+	Stacked
+	// Lines draws one line per series across the categories
+	Lines
+)
+
+const (
+	FormatSVG = "svg"
+	FormatPNG = "png"
+)
+
+// Series is one brand of a chart, with a value per category
+type Series struct {
+	Name   string
+	Values []float64
+}
+
+type Chart struct {
+	Kind       Kind
+	Title      string
+	Categories []string
+	Series     []Series
+}
+
+const (
+	width  = 960
+	height = 540
+
+	plotLeft   = 70
+	plotRight  = width - 30
+	plotTop    = 90
+	plotBottom = height - 50
+)
+
+var (
+	palette = []color.RGBA{
+		{0x1f, 0x77, 0xb4, 0xff},
+		{0xff, 0x7f, 0x0e, 0xff},
+		{0x2c, 0xa0, 0x2c, 0xff},
+		{0xd6, 0x27, 0x28, 0xff},
+		{0x94, 0x67, 0xbd, 0xff},
+		{0x8c, 0x56, 0x4b, 0xff},
+		{0xe3, 0x77, 0xc2, 0xff},
+		{0x7f, 0x7f, 0x7f, 0xff},
+	}
+	textColor = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	gridColor = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	white     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+type anchor int
+
+const (
+	start anchor = iota
+	middle
+	end
+)
+
+// canvas is what a chart is drawn on, SVG or an image
+type canvas interface {
+	rect(x, y, w, h float64, fill color.RGBA)
+	polyline(points [][2]float64, stroke color.RGBA, width float64)
+	text(x, y float64, s string, size float64, fill color.RGBA, a anchor)
+}
+
+// Render draws a chart as SVG or PNG and returns it with its content type
+func Render(c Chart, format string) ([]byte, string, error) {
+	for _, s := range c.Series {
+		if len(s.Values) != len(c.Categories) {
+			return nil, "", fmt.Errorf("series %s has %d values for %d categories", s.Name, len(s.Values), len(c.Categories))
+		}
+	}
+
+	switch format {
+	case FormatSVG:
+		cv := newSVG(width, height)
+		plot(cv, c)
+		return cv.bytes(), "image/svg+xml", nil
+	case FormatPNG:
+		cv := newPNG(width, height)
+		plot(cv, c)
+		b, err := cv.bytes()
+		return b, "image/png", err
+	default:
+		return nil, "", errors.New("unknown chart format " + format)
+	}
+}
+
+func plot(cv canvas, c Chart) {
+	cv.rect(0, 0, width, height, white)
+	cv.text(width/2, 32, c.Title, 18, textColor, middle)
+
+	// Legend
+	x := float64(plotLeft)
+	for i, s := range c.Series {
+		cv.rect(x, 52, 12, 12, palette[i%len(palette)])
+		cv.text(x+18, 63, s.Name, 12, textColor, start)
+		x += 36 + float64(len([]rune(s.Name)))*9
+	}
+
+	maxValue := 100.0
+	if c.Kind != Stacked {
+		maxValue = 0
+		for _, s := range c.Series {
+			for _, v := range s.Values {
+				maxValue = math.Max(maxValue, v)
+			}
+		}
+	}
+	step := niceStep(maxValue)
+	top := math.Max(step, math.Ceil(maxValue/step)*step)
+	y := func(v float64) float64 {
+		return plotBottom - v/top*(plotBottom-plotTop)
+	}
+
+	// Grid and value axis
+	for v := 0.0; v <= top+step/2; v += step {
+		cv.polyline([][2]float64{{plotLeft, y(v)}, {plotRight, y(v)}}, gridColor, 1)
+		label := formatValue(v)
+		if c.Kind == Stacked {
+			label += "%"
+		}
+		cv.text(plotLeft-8, y(v)+4, label, 11, textColor, end)
+	}
+
+	n := len(c.Categories)
+	if n == 0 {
+		return
+	}
+	band := float64(plotRight-plotLeft) / float64(n)
+	for i, category := range c.Categories {
+		cv.text(plotLeft+band*(float64(i)+0.5), plotBottom+20, category, 12, textColor, middle)
+	}
+
+	switch c.Kind {
+	case Bars:
+		if len(c.Series) == 0 {
+			return
+		}
+		barWidth := band * 0.8 / float64(len(c.Series))
+		for i := range c.Categories {
+			x := plotLeft + band*float64(i) + band*0.1
+			for j, s := range c.Series {
+				v := s.Values[i]
+				cv.rect(x+barWidth*float64(j), y(v), barWidth, plotBottom-y(v), palette[j%len(palette)])
+			}
+		}
+
+	case Stacked:
+		for i := range c.Categories {
+			total := 0.0
+			for _, s := range c.Series {
+				total += s.Values[i]
+			}
+			if total == 0 {
+				continue
+			}
+			x := plotLeft + band*float64(i) + band*0.2
+			base := 0.0
+			for j, s := range c.Series {
+				share := s.Values[i] / total * 100
+				cv.rect(x, y(base+share), band*0.6, y(base)-y(base+share), palette[j%len(palette)])
+				base += share
+			}
+		}
+
+	case Lines:
+		for j, s := range c.Series {
+			points := make([][2]float64, len(s.Values))
+			for i, v := range s.Values {
+				points[i] = [2]float64{plotLeft + band*(float64(i)+0.5), y(v)}
+			}
+			cv.polyline(points, palette[j%len(palette)], 2.5)
+			for _, p := range points {
+				cv.rect(p[0]-3, p[1]-3, 6, 6, palette[j%len(palette)])
+			}
+		}
+	}
+}
+
+// niceStep picks a grid step of 1, 2 or 5 times a power of ten giving
+// about five lines
+func niceStep(max float64) float64 {
+	if max <= 0 {
+		return 1
+	}
+	raw := max / 5
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if raw <= m*magnitude {
+			return math.Max(1, m*magnitude)
+		}
+	}
+	return 10 * magnitude
+}
+
+func formatValue(v float64) string {
+	if v >= 1000 && math.Mod(v, 1000) == 0 {
+		return fmt.Sprintf("%gk", v/1000)
+	}
+	return fmt.Sprintf("%g", v)
+}
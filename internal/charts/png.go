@@ -0,0 +1,95 @@
+package charts
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// The Go fonts cover Cyrillic, so region and brand names render as is
+var regular = sync.OnceValue(func() *opentype.Font {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		panic(err)
+	}
+	return f
+})
+
+type pngCanvas struct {
+	img   *image.RGBA
+	faces map[float64]font.Face
+}
+
+func newPNG(w, h int) *pngCanvas {
+	return &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, w, h)), faces: map[float64]font.Face{}}
+}
+
+func (cv *pngCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	draw.Draw(cv.img, r, image.NewUniform(fill), image.Point{}, draw.Over)
+}
+
+// polyline strokes every segment as a quad, anti-aliased by the rasterizer
+func (cv *pngCanvas) polyline(points [][2]float64, stroke color.RGBA, width float64) {
+	b := cv.img.Bounds()
+	r := vector.NewRasterizer(b.Dx(), b.Dy())
+	for i := 1; i < len(points); i++ {
+		x0, y0 := points[i-1][0], points[i-1][1]
+		x1, y1 := points[i][0], points[i][1]
+		length := math.Hypot(x1-x0, y1-y0)
+		if length == 0 {
+			continue
+		}
+		// Offset perpendicular to the segment by half the width
+		nx, ny := -(y1-y0)/length*width/2, (x1-x0)/length*width/2
+		r.MoveTo(float32(x0+nx), float32(y0+ny))
+		r.LineTo(float32(x1+nx), float32(y1+ny))
+		r.LineTo(float32(x1-nx), float32(y1-ny))
+		r.LineTo(float32(x0-nx), float32(y0-ny))
+		r.ClosePath()
+	}
+	r.Draw(cv.img, b, image.NewUniform(stroke), image.Point{})
+}
+
+func (cv *pngCanvas) text(x, y float64, s string, size float64, fill color.RGBA, a anchor) {
+	face := cv.face(size)
+	d := font.Drawer{Dst: cv.img, Src: image.NewUniform(fill), Face: face}
+
+	advance := float64(d.MeasureString(s)) / 64
+	switch a {
+	case middle:
+		x -= advance / 2
+	case end:
+		x -= advance
+	}
+	d.Dot = fixed.P(int(math.Round(x)), int(math.Round(y)))
+	d.DrawString(s)
+}
+
+func (cv *pngCanvas) face(size float64) font.Face {
+	if f, ok := cv.faces[size]; ok {
+		return f
+	}
+	f, err := opentype.NewFace(regular(), &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+	cv.faces[size] = f
+	return f
+}
+
+func (cv *pngCanvas) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, cv.img)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,49 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+)
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func newSVG(w, h int) *svgCanvas {
+	cv := &svgCanvas{}
+	fmt.Fprintf(&cv.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`, w, h, w, h)
+	return cv
+}
+
+func (cv *svgCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&cv.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, x, y, w, h, hex(fill))
+}
+
+func (cv *svgCanvas) polyline(points [][2]float64, stroke color.RGBA, width float64) {
+	cv.buf.WriteString(`<polyline fill="none" points="`)
+	for i, p := range points {
+		if i > 0 {
+			cv.buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&cv.buf, "%.1f,%.1f", p[0], p[1])
+	}
+	fmt.Fprintf(&cv.buf, `" stroke="%s" stroke-width="%g"/>`, hex(stroke), width)
+}
+
+func (cv *svgCanvas) text(x, y float64, s string, size float64, fill color.RGBA, a anchor) {
+	anchors := map[anchor]string{start: "start", middle: "middle", end: "end"}
+	fmt.Fprintf(&cv.buf, `<text x="%.1f" y="%.1f" font-size="%g" fill="%s" text-anchor="%s">`, x, y, size, hex(fill), anchors[a])
+	xml.EscapeText(&cv.buf, []byte(s))
+	cv.buf.WriteString(`</text>`)
+}
+
+func (cv *svgCanvas) bytes() []byte {
+	cv.buf.WriteString(`</svg>`)
+	return cv.buf.Bytes()
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"truck-analytics-platform/internal/metrics"
+	"truck-analytics-platform/internal/segments"
+)
+
+// AggregatesView holds registrations pre-aggregated by
+// dataset × segment × month × region × brand. Report queries read from it
+// instead of scanning the raw truck_analytics_* tables.
+const AggregatesView = "truck_analytics_segment_monthly"
+
+// aggregatesVersion is bumped when aggregatesQuery changes so that existing
+// views get rebuilt
+const aggregatesVersion = "v3"
+
+// RefreshAggregates brings AggregatesView up to date. It has to run after
+// registration data is loaded. When the set of truck_analytics_* tables
+// changed the view is rebuilt, otherwise it is refreshed in place without
+// blocking readers.
+func RefreshAggregates(ctx context.Context, conn DB) error {
+	start := time.Now()
+	mode, tables, err := refreshAggregates(ctx, conn)
+	metrics.ObserveRefresh(mode, tables, time.Since(start), err)
+	return err
+}
+
+func refreshAggregates(ctx context.Context, conn DB) (mode string, count int, err error) {
+	datasets, err := Datasets(ctx, conn)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(datasets) == 0 {
+		return "", 0, errors.New("no truck_analytics tables found")
+	}
+
+	tables := make([]string, len(datasets))
+	for i, d := range datasets {
+		tables[i] = d.Table
+	}
+	signature := datasetsSignature(datasets)
+
+	current, err := AggregatesCurrent(ctx, conn, datasets)
+	if err != nil {
+		return "", len(tables), err
+	}
+
+	if current {
+		_, err = conn.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY `+AggregatesView)
+		if err != nil {
+			return "refresh", len(tables), err
+		}
+		slog.Info("Refreshed aggregates", "view", AggregatesView)
+		return "refresh", len(tables), nil
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return "rebuild", len(tables), err
+	}
+	defer tx.Rollback(ctx)
+
+	statements := []string{
+		`DROP MATERIALIZED VIEW IF EXISTS ` + AggregatesView,
+		`CREATE MATERIALIZED VIEW ` + AggregatesView + ` AS ` + aggregatesQuery(tables),
+		`CREATE UNIQUE INDEX ON ` + AggregatesView + ` ("Dataset", "Segment", "Month_of_registration", "Federal_district", "Region", "Brand")`,
+		`COMMENT ON MATERIALIZED VIEW ` + AggregatesView + ` IS ` + segments.Literal(signature),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return "rebuild", len(tables), fmt.Errorf("rebuild %s: %w", AggregatesView, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "rebuild", len(tables), err
+	}
+	slog.Info("Rebuilt aggregates", "view", AggregatesView, "tables", len(tables))
+	return "rebuild", len(tables), nil
+}
+
+// AggregatesCurrent reports whether AggregatesView exists and covers every
+// loaded registration table
+func AggregatesCurrent(ctx context.Context, conn DB, datasets []Dataset) (bool, error) {
+	var current *string
+	err := conn.QueryRow(ctx, `SELECT obj_description(to_regclass($1), 'pg_class')`, AggregatesView).Scan(&current)
+	if err != nil || current == nil {
+		return false, err
+	}
+	return *current == datasetsSignature(datasets), nil
+}
+
+// datasetsSignature is the view version and the list of source tables kept
+// in the view comment
+func datasetsSignature(datasets []Dataset) string {
+	tables := make([]string, len(datasets))
+	for i, d := range datasets {
+		tables[i] = d.Table
+	}
+	return aggregatesVersion + ":" + strings.Join(tables, ",")
+}
+
+// aggregatesQuery reports brands under their canonical names and adds the
+// region and district codes from the directory, see
+// migrations/0002_brands.sql and 0003_regions.sql. The directory has to be
+// synced before.
+func aggregatesQuery(tables []string) string {
+	var parts []string
+	for _, table := range tables {
+		for _, s := range segments.All {
+			parts = append(parts, fmt.Sprintf(`
+			SELECT
+				%s AS "Dataset",
+				%s AS "Segment",
+				"Month_of_registration",
+				"Federal_district",
+				"Region",
+				district_code("Federal_district") AS "District_code",
+				region_code("Region") AS "Region_code",
+				canonical_brand("Brand") AS "Brand",
+				SUM("Quantity") AS "Quantity"
+			FROM %s
+			WHERE %s
+			GROUP BY "Month_of_registration", "Federal_district", "Region", canonical_brand("Brand")`,
+				segments.Literal(table), segments.Literal(s.Name), table, s.Predicate()))
+		}
+	}
+	return strings.Join(parts, "\n\t\t\tUNION ALL")
+}
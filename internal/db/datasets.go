@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// Registration data is loaded into one table per period, named
+// truck_analytics_<year>_<first month>_<last month>
+var datasetTable = regexp.MustCompile(`^truck_analytics_(\d{4})_(\d{2})_(\d{2})$`)
+
+type Dataset struct {
+	Table     string `json:"table"`
+	Year      int    `json:"year"`
+	FromMonth int    `json:"from_month"`
+	ToMonth   int    `json:"to_month"`
+}
+
+// Datasets lists the registration tables present in the database, oldest first
+func Datasets(ctx context.Context, conn DB) ([]Dataset, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = current_schema()
+			AND table_type = 'BASE TABLE'
+			AND table_name LIKE 'truck\_analytics\_%'
+		ORDER BY table_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	datasets := []Dataset{}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+
+		if d, ok := ParseDataset(table); ok {
+			datasets = append(datasets, d)
+		}
+	}
+
+	return datasets, rows.Err()
+}
+
+// ParseDataset reads the period of a registration table from its name
+func ParseDataset(table string) (Dataset, bool) {
+	m := datasetTable.FindStringSubmatch(table)
+	if m == nil {
+		return Dataset{}, false
+	}
+	year, _ := strconv.Atoi(m[1])
+	from, _ := strconv.Atoi(m[2])
+	to, _ := strconv.Atoi(m[3])
+
+	return Dataset{Table: table, Year: year, FromMonth: from, ToMonth: to}, true
+}
+
+// PickDataset finds the dataset of a year covering the most months. Months
+// 0 accepts any dataset of the year.
+func PickDataset(datasets []Dataset, year, months int) (Dataset, bool) {
+	var best Dataset
+	for _, d := range datasets {
+		if d.Year == year && d.ToMonth > best.ToMonth && (months == 0 || d.FromMonth <= months) {
+			best = d
+		}
+	}
+	return best, best.Table != ""
+}
+
+var (
+	ErrUnknownDataset = errors.New("unknown dataset")
+	ErrNoDataset      = errors.New("no registration data for the period")
+)
+
+// SelectDataset picks the dataset a request asks for: the table when given,
+// else the dataset of the year covering the most months, else the latest
+// one loaded
+func SelectDataset(datasets []Dataset, table string, year, months int) (Dataset, error) {
+	switch {
+	case table != "":
+		i := slices.IndexFunc(datasets, func(d Dataset) bool { return d.Table == table })
+		if i < 0 {
+			return Dataset{}, fmt.Errorf("%w %q", ErrUnknownDataset, table)
+		}
+		return datasets[i], nil
+	case year != 0:
+		if d, ok := PickDataset(datasets, year, months); ok {
+			return d, nil
+		}
+	case len(datasets) > 0:
+		return datasets[len(datasets)-1], nil
+	}
+	return Dataset{}, ErrNoDataset
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var massSegmentColumn = regexp.MustCompile(`^(Mass|Weight)_in_segment_\d+$`)
+
+// DistinctValues returns the distinct non-null values of a column across the
+// given registration tables. Tables that don't have the column are skipped.
+// The column name must come from code, not from a request.
+func DistinctValues(ctx context.Context, conn DB, column string, datasets []Dataset) ([]string, error) {
+	columns, err := DatasetColumns(ctx, conn, datasets)
+	if err != nil {
+		return nil, err
+	}
+
+	col := pgx.Identifier{column}.Sanitize()
+	var parts []string
+	for _, d := range datasets {
+		if !columns[d.Table][column] {
+			continue
+		}
+		parts = append(parts, `SELECT DISTINCT `+col+`::text FROM `+pgx.Identifier{d.Table}.Sanitize()+` WHERE `+col+` IS NOT NULL`)
+	}
+	if len(parts) == 0 {
+		return []string{}, nil
+	}
+
+	rows, err := conn.Query(ctx, strings.Join(parts, " UNION ")+` ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
+
+// MassSegmentColumns lists the mass segmentation columns (Mass_in_segment_N,
+// Weight_in_segment_N) present in any of the registration tables
+func MassSegmentColumns(ctx context.Context, conn DB, datasets []Dataset) ([]string, error) {
+	columns, err := DatasetColumns(ctx, conn, datasets)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, cols := range columns {
+		for c := range cols {
+			if massSegmentColumn.MatchString(c) {
+				seen[c] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(seen))
+	for c := range seen {
+		result = append(result, c)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// DatasetColumns maps table name to the set of its columns
+func DatasetColumns(ctx context.Context, conn DB, datasets []Dataset) (map[string]map[string]bool, error) {
+	tables := make([]string, len(datasets))
+	for i, d := range datasets {
+		tables[i] = d.Table
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ANY($1)
+	`, tables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if columns[table] == nil {
+			columns[table] = make(map[string]bool)
+		}
+		columns[table][column] = true
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema changes live in migrations/NNNN_description.sql and are applied in order
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+}
+
+func migrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []migration
+	for _, e := range entries {
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version prefix", e.Name())
+		}
+		result = append(result, migration{version: version, name: e.Name()})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].version < result[j].version })
+
+	return result, nil
+}
+
+// Migrate applies the migrations that haven't been applied yet
+func Migrate(ctx context.Context, conn DB) error {
+	_, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	current, err := currentVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	all, err := migrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range all {
+		if m.version <= current {
+			continue
+		}
+
+		sql, err := migrationFiles.ReadFile("migrations/" + m.name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version); err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+
+		slog.Info("Applied migration", "name", m.name)
+	}
+
+	return nil
+}
+
+// MigrationVersion returns the applied and the latest known schema version
+func MigrationVersion(ctx context.Context, conn DB) (current, latest int, err error) {
+	all, err := migrations()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(all) > 0 {
+		latest = all[len(all)-1].version
+	}
+
+	current, err = currentVersion(ctx, conn)
+	return current, latest, err
+}
+
+func currentVersion(ctx context.Context, conn DB) (int, error) {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	err = conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
@@ -0,0 +1,15 @@
+CREATE TABLE api_keys (
+	id           BIGSERIAL PRIMARY KEY,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	key_hash     BYTEA NOT NULL UNIQUE,
+	admin        BOOLEAN NOT NULL DEFAULT FALSE,
+	-- Empty arrays mean no restriction
+	segments     TEXT[] NOT NULL DEFAULT '{}',
+	years        INTEGER[] NOT NULL DEFAULT '{}',
+	brands       TEXT[] NOT NULL DEFAULT '{}',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	rotated_at   TIMESTAMPTZ,
+	revoked_at   TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ
+);
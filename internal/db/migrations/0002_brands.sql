@@ -0,0 +1,43 @@
+-- Canonical brand names. Reports and aggregates use these instead of the
+-- raw "Brand" values of the registration tables.
+CREATE TABLE brands (
+	name       TEXT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Other spellings of a brand, stored as brand_key(alias)
+CREATE TABLE brand_aliases (
+	alias      TEXT PRIMARY KEY,
+	brand      TEXT NOT NULL REFERENCES brands (name) ON UPDATE CASCADE ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Case and spacing insensitive form of a brand value
+CREATE FUNCTION brand_key(raw TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE AS $$
+	SELECT upper(btrim(regexp_replace(raw, '[\s-]+', ' ', 'g')))
+$$;
+
+-- Canonical name of a raw brand value, the value itself when it's unknown
+CREATE FUNCTION canonical_brand(raw TEXT) RETURNS TEXT
+LANGUAGE sql STABLE AS $$
+	SELECT COALESCE(
+		(SELECT name FROM brands WHERE brand_key(name) = brand_key(raw) LIMIT 1),
+		(SELECT brand FROM brand_aliases WHERE alias = brand_key(raw)),
+		raw
+	)
+$$;
+
+INSERT INTO brands (name) VALUES
+	('DONGFENG'), ('FAW'), ('FOTON'), ('HOWO'), ('JAC'), ('SANY'), ('SHACMAN'), ('SITRAK');
+
+INSERT INTO brand_aliases (alias, brand) VALUES
+	('DONG FENG', 'DONGFENG'),
+	('FAW JIEFANG', 'FAW'),
+	('JIEFANG', 'FAW'),
+	('FOTON AUMAN', 'FOTON'),
+	('SINOTRUK HOWO', 'HOWO'),
+	('SANY HEAVY', 'SANY'),
+	('SHAANXI', 'SHACMAN'),
+	('SHAANXI SHACMAN', 'SHACMAN'),
+	('SINOTRUK SITRAK', 'SITRAK');
@@ -0,0 +1,43 @@
+-- Reference directory of federal districts and subjects, filled from the
+-- geo package at startup
+CREATE TABLE federal_districts (
+	code    TEXT PRIMARY KEY,
+	name    TEXT NOT NULL UNIQUE,
+	name_en TEXT NOT NULL,
+	short   TEXT NOT NULL
+);
+
+CREATE TABLE regions (
+	code     TEXT PRIMARY KEY,
+	okato    TEXT,
+	name     TEXT NOT NULL UNIQUE,
+	name_en  TEXT NOT NULL,
+	district TEXT NOT NULL REFERENCES federal_districts (code)
+);
+
+-- Every known spelling of a region or district, stored as region_key(name)
+CREATE TABLE region_aliases (
+	key      TEXT PRIMARY KEY,
+	region   TEXT REFERENCES regions (code) ON DELETE CASCADE,
+	district TEXT REFERENCES federal_districts (code) ON DELETE CASCADE,
+	CHECK ((region IS NULL) <> (district IS NULL))
+);
+
+-- Case, ё and dash insensitive form of a region name, same as geo.normalize
+CREATE FUNCTION region_key(raw TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE AS $$
+	SELECT regexp_replace(
+		btrim(regexp_replace(replace(translate(lower(raw), 'ё—–', 'е--'), 'г.', 'г. '), '\s+', ' ', 'g')),
+		' ?- ?', '-', 'g')
+$$;
+
+-- Codes of raw "Region" and "Federal_district" values, NULL when unknown
+CREATE FUNCTION region_code(raw TEXT) RETURNS TEXT
+LANGUAGE sql STABLE AS $$
+	SELECT region FROM region_aliases WHERE key = region_key(raw)
+$$;
+
+CREATE FUNCTION district_code(raw TEXT) RETURNS TEXT
+LANGUAGE sql STABLE AS $$
+	SELECT district FROM region_aliases WHERE key = region_key(raw)
+$$;
@@ -0,0 +1,41 @@
+-- Reports produced and delivered on a schedule or when new data is loaded
+CREATE TABLE report_jobs (
+	id             BIGSERIAL PRIMARY KEY,
+	name           TEXT NOT NULL,
+	-- 5 field cron expression in UTC, empty for jobs run on new data or by hand
+	schedule       TEXT NOT NULL DEFAULT '',
+	on_new_data    BOOLEAN NOT NULL DEFAULT FALSE,
+	report         TEXT NOT NULL,
+	params         JSONB NOT NULL DEFAULT '{}',
+	format         TEXT NOT NULL,
+	email          TEXT[] NOT NULL DEFAULT '{}',
+	directory      TEXT NOT NULL DEFAULT '',
+	max_attempts   INTEGER NOT NULL DEFAULT 3,
+	enabled        BOOLEAN NOT NULL DEFAULT TRUE,
+	next_run_at    TIMESTAMPTZ,
+	-- Registration tables present at the last run on new data
+	data_signature TEXT,
+	created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ON report_jobs (next_run_at) WHERE enabled;
+
+-- One row per attempt
+CREATE TABLE report_job_runs (
+	id          BIGSERIAL PRIMARY KEY,
+	job_id      BIGINT NOT NULL REFERENCES report_jobs (id) ON DELETE CASCADE,
+	trigger     TEXT NOT NULL,
+	attempt     INTEGER NOT NULL,
+	status      TEXT NOT NULL,
+	error       TEXT,
+	output      TEXT,
+	size_bytes  INTEGER,
+	started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	finished_at TIMESTAMPTZ,
+	-- Set on failed attempts that will be retried
+	retry_at    TIMESTAMPTZ
+);
+
+CREATE INDEX ON report_job_runs (job_id, started_at DESC);
+CREATE INDEX ON report_job_runs (retry_at) WHERE retry_at IS NOT NULL;
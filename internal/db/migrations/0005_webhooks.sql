@@ -0,0 +1,58 @@
+CREATE TABLE webhook_subscriptions (
+	id          BIGSERIAL PRIMARY KEY,
+	url         TEXT NOT NULL,
+	secret      TEXT NOT NULL,
+	-- Empty means every event
+	events      TEXT[] NOT NULL DEFAULT '{}',
+	description TEXT NOT NULL DEFAULT '',
+	enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE webhook_events (
+	id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	type       TEXT NOT NULL,
+	data       JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One row per event and subscription, updated on every attempt
+CREATE TABLE webhook_deliveries (
+	id              BIGSERIAL PRIMARY KEY,
+	event_id        UUID NOT NULL REFERENCES webhook_events (id) ON DELETE CASCADE,
+	subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+	status          TEXT NOT NULL DEFAULT 'pending',
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ DEFAULT now(),
+	response_status INTEGER,
+	error           TEXT,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX ON webhook_deliveries (subscription_id, created_at DESC);
+
+-- Registration tables already announced. Tables present before webhooks
+-- existed are not announced.
+CREATE TABLE seen_datasets (
+	table_name TEXT PRIMARY KEY,
+	seen_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO seen_datasets (table_name)
+SELECT table_name FROM information_schema.tables
+WHERE table_schema = current_schema()
+	AND table_type = 'BASE TABLE'
+	AND table_name ~ '^truck_analytics_\d{4}_\d{2}_\d{2}$';
+
+-- Fingerprint of every report in the aggregates, to tell when numbers change
+CREATE TABLE report_checksums (
+	dataset    TEXT NOT NULL,
+	segment    TEXT NOT NULL,
+	checksum   TEXT NOT NULL,
+	total      BIGINT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (dataset, segment)
+);
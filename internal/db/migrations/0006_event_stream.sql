@@ -0,0 +1,4 @@
+-- Order of events for clients resuming a stream with Last-Event-ID. Events
+-- of one transaction share created_at.
+ALTER TABLE webhook_events ADD COLUMN seq BIGSERIAL;
+CREATE UNIQUE INDEX ON webhook_events (seq);
@@ -0,0 +1,39 @@
+-- Saved report definitions and dashboards. Items without an owner were
+-- created with authentication disabled or with the bootstrap admin key.
+CREATE TABLE report_definitions (
+	id           BIGSERIAL PRIMARY KEY,
+	owner_key_id BIGINT REFERENCES api_keys (id) ON DELETE CASCADE,
+	name         TEXT NOT NULL,
+	description  TEXT NOT NULL DEFAULT '',
+	segment      TEXT NOT NULL,
+	-- 0 means the latest year loaded
+	year         INTEGER NOT NULL DEFAULT 0,
+	-- 0 means every month loaded
+	months       INTEGER NOT NULL DEFAULT 0,
+	-- Empty means the segment brands
+	brands       TEXT[] NOT NULL DEFAULT '{}',
+	compare      TEXT NOT NULL DEFAULT '',
+	format       TEXT NOT NULL DEFAULT 'json',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX ON report_definitions (COALESCE(owner_key_id, 0), name);
+
+CREATE TABLE dashboards (
+	id           BIGSERIAL PRIMARY KEY,
+	owner_key_id BIGINT REFERENCES api_keys (id) ON DELETE CASCADE,
+	name         TEXT NOT NULL,
+	description  TEXT NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX ON dashboards (COALESCE(owner_key_id, 0), name);
+
+CREATE TABLE dashboard_items (
+	dashboard_id  BIGINT NOT NULL REFERENCES dashboards (id) ON DELETE CASCADE,
+	position      INTEGER NOT NULL,
+	definition_id BIGINT NOT NULL REFERENCES report_definitions (id) ON DELETE CASCADE,
+	PRIMARY KEY (dashboard_id, position)
+);
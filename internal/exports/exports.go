@@ -0,0 +1,157 @@
+package exports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	ContentTypeCSV  = "text/csv; charset=utf-8"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Table is one sheet of an export
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// Encode writes the tables in a format and returns its content type
+func Encode(format string, tables []Table) (string, []byte, error) {
+	switch format {
+	case FormatCSV:
+		data, err := CSV(tables)
+		return ContentTypeCSV, data, err
+	case FormatXLSX:
+		data, err := XLSX(tables)
+		return ContentTypeXLSX, data, err
+	}
+	return "", nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// CSV writes the tables one after another, each preceded by its name when
+// there is more than one. The byte order mark makes Excel read UTF-8.
+func CSV(tables []Table) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+
+	for i, t := range tables {
+		if len(tables) > 1 {
+			if i > 0 {
+				w.Write(nil)
+			}
+			w.Write([]string{t.Name})
+		}
+		w.Write(t.Header)
+		for _, row := range t.Rows {
+			record := make([]string, len(row))
+			for j, v := range row {
+				if v != nil {
+					record[j] = fmt.Sprint(v)
+				}
+			}
+			w.Write(record)
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// XLSX writes every table to its own sheet
+func XLSX(tables []Table) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, t := range tables {
+		sheet := sheetName(t.Name, i)
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheet); err != nil {
+				return nil, err
+			}
+		} else if _, err := f.NewSheet(sheet); err != nil {
+			return nil, err
+		}
+
+		header := make([]any, len(t.Header))
+		for j, h := range t.Header {
+			header[j] = h
+		}
+		if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+			return nil, err
+		}
+		if len(t.Header) > 0 {
+			last, _ := excelize.CoordinatesToCellName(len(t.Header), 1)
+			f.SetCellStyle(sheet, "A1", last, bold)
+		}
+		for r, row := range t.Rows {
+			cell, _ := excelize.CoordinatesToCellName(1, r+2)
+			if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sheetName fits a table name into Excel's 31 character limit
+func sheetName(name string, i int) string {
+	if name == "" {
+		name = fmt.Sprintf("Sheet%d", i+1)
+	}
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	return name
+}
+
+// Text aligns the tables in columns for terminals, empty cells shown as "-"
+func Text(tables []Table) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, t := range tables {
+		if len(tables) > 1 {
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(t.Name + "\n")
+		}
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(t.Header, "\t"))
+		for _, row := range t.Rows {
+			for j, v := range row {
+				if j > 0 {
+					fmt.Fprint(w, "\t")
+				}
+				if v == nil {
+					v = "-"
+				}
+				fmt.Fprint(w, v)
+			}
+			fmt.Fprintln(w)
+		}
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,343 @@
+// Package forecast projects monthly registrations of a segment per brand.
+// History is the most complete dataset of every year; seasonal models are
+// fitted on its latest unbroken stretch of months and backtested on the
+// last of them.
+package forecast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/segments"
+)
+
+var (
+	ErrInvalid      = errors.New("invalid forecast")
+	ErrNoAccess     = errors.New("API key has no access to this data")
+	ErrNoData       = errors.New("no registration data for the segment")
+	ErrShortHistory = errors.New("not enough history for the model")
+)
+
+const (
+	ModelAuto          = "auto"
+	ModelSeasonalNaive = "seasonal_naive"
+	ModelHoltWinters   = "holt_winters"
+
+	// MaxHorizon is the furthest month a forecast reaches
+	MaxHorizon = 24
+)
+
+// Models are the models that can be asked for, auto picks the one with the
+// lowest backtest MAE
+var Models = []string{ModelAuto, ModelSeasonalNaive, ModelHoltWinters}
+
+// Levels map the supported prediction interval levels, in percent, to
+// their normal quantile
+var Levels = map[int]float64{80: 1.2816, 90: 1.6449, 95: 1.96, 99: 2.5758}
+
+// Request describes a forecast. Horizon is extended to the end of the year
+// of the first forecast month so the full-year outlook is always complete.
+type Request struct {
+	Segment string
+	// Brands overrides the segment brands
+	Brands  []string
+	Model   string
+	Horizon int
+	Level   int
+}
+
+// Actual is the registrations of a month, nil when no dataset covers it
+type Actual struct {
+	Month string `json:"month"`
+	Value *int64 `json:"value"`
+}
+
+// Point is the forecast of a month with its prediction interval
+type Point struct {
+	Month string  `json:"month"`
+	Value float64 `json:"value"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// Outlook is the expected registrations of a quarter or year, the months
+// already loaded counted in Actual and included in Value
+type Outlook struct {
+	Period string  `json:"period"`
+	Actual int64   `json:"actual"`
+	Value  float64 `json:"value"`
+	Lower  float64 `json:"lower"`
+	Upper  float64 `json:"upper"`
+}
+
+// Candidate is a model tried on a series. Backtest is nil when the history
+// is too short to hold months out.
+type Candidate struct {
+	Model    string   `json:"model"`
+	Backtest *Metrics `json:"backtest"`
+}
+
+// Series is the forecast of a brand, or of the segment total
+type Series struct {
+	Brand       string      `json:"brand,omitempty"`
+	Model       string      `json:"model"`
+	Candidates  []Candidate `json:"candidates"`
+	History     []Actual    `json:"history"`
+	Forecast    []Point     `json:"forecast"`
+	NextQuarter Outlook     `json:"next_quarter"`
+	FullYear    Outlook     `json:"full_year"`
+}
+
+type Result struct {
+	Segment  string   `json:"segment"`
+	Datasets []string `json:"datasets"`
+	Level    int      `json:"level"`
+	Horizon  int      `json:"horizon"`
+	Brands   []Series `json:"brands"`
+	Total    Series   `json:"total"`
+}
+
+// Run forecasts the brands of a segment from the years the permissions allow
+func Run(ctx context.Context, conn db.DB, req Request, p auth.Permissions) (Result, error) {
+	if req.Model == "" {
+		req.Model = ModelAuto
+	}
+	if req.Level == 0 {
+		req.Level = 95
+	}
+	segment, ok := segments.Get(req.Segment)
+	switch {
+	case !ok:
+		return Result{}, fmt.Errorf("%w: unknown segment %q", ErrInvalid, req.Segment)
+	case !slices.Contains(Models, req.Model):
+		return Result{}, fmt.Errorf("%w: unknown model %q", ErrInvalid, req.Model)
+	case req.Horizon < 0 || req.Horizon > MaxHorizon:
+		return Result{}, fmt.Errorf("%w: horizon must be between 0 and %d, 0 forecasts to the end of the year", ErrInvalid, MaxHorizon)
+	}
+	z, ok := Levels[req.Level]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: level must be 80, 90, 95 or 99", ErrInvalid)
+	}
+
+	names := segment.Brands
+	if len(req.Brands) > 0 {
+		var err error
+		if names, err = brands.Canonical(ctx, conn, req.Brands); err != nil {
+			return Result{}, err
+		}
+	}
+	names = p.AllowedBrands(names)
+	if len(names) == 0 {
+		return Result{}, ErrNoAccess
+	}
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return Result{}, err
+	}
+	h, err := loadHistory(ctx, conn, datasets, segment.Name, names, p)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Forecast at least to the end of the year of the first forecast month
+	first := h.Start + len(h.Total)
+	horizon := max(req.Horizon, season-first%season)
+
+	result := Result{Segment: segment.Name, Datasets: h.Datasets, Level: req.Level, Horizon: horizon}
+	for i, brand := range names {
+		s, err := forecastSeries(h, h.Brands[i], req.Model, horizon, z)
+		if err != nil {
+			return Result{}, err
+		}
+		s.Brand = brand
+		result.Brands = append(result.Brands, s)
+	}
+	if result.Total, err = forecastSeries(h, h.Total, req.Model, horizon, z); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+// history is the monthly registrations of a segment. Months are counted
+// from year 0, month index = year*12 + month-1; NaN marks months no
+// dataset covers.
+type history struct {
+	Datasets []string
+	Start    int
+	Brands   [][]float64
+	Total    []float64
+}
+
+// loadHistory runs the monthly report on the most complete dataset of every
+// year the key may query
+func loadHistory(ctx context.Context, conn db.DB, datasets []db.Dataset, segment string, names []string, p auth.Permissions) (history, error) {
+	var picked []db.Dataset
+	for i, d := range datasets {
+		if i > 0 && datasets[i-1].Year == d.Year || !p.AllowsReport(segment, d.Year) {
+			continue
+		}
+		best, _ := db.PickDataset(datasets, d.Year, 0)
+		picked = append(picked, best)
+	}
+	if len(picked) == 0 {
+		if len(datasets) > 0 {
+			return history{}, ErrNoAccess
+		}
+		return history{}, ErrNoData
+	}
+
+	first, last := picked[0], picked[len(picked)-1]
+	h := history{
+		Start:  monthIndex(first.Year, first.FromMonth),
+		Brands: make([][]float64, len(names)),
+	}
+	months := monthIndex(last.Year, last.ToMonth) - h.Start + 1
+	for i := range names {
+		h.Brands[i] = gaps(months)
+	}
+	h.Total = gaps(months)
+
+	for _, d := range picked {
+		h.Datasets = append(h.Datasets, d.Table)
+		monthly, err := reports.Monthly(ctx, conn, reports.Query{Dataset: d.Table, Segment: segment, Brands: names})
+		if err != nil {
+			return history{}, err
+		}
+
+		for m := d.FromMonth; m <= d.ToMonth; m++ {
+			t := monthIndex(d.Year, m) - h.Start
+			h.Total[t] = 0
+			for i := range names {
+				h.Brands[i][t] = 0
+			}
+		}
+		for j, m := range monthly.Months {
+			t := monthIndex(d.Year, m) - h.Start
+			for i := range names {
+				v := float64(monthly.Volumes[i][j])
+				h.Brands[i][t] = v
+				h.Total[t] += v
+			}
+		}
+	}
+	return h, nil
+}
+
+func gaps(n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+func monthIndex(year, month int) int {
+	return year*season + month - 1
+}
+
+func monthLabel(index int) string {
+	return fmt.Sprintf("%04d-%02d", index/season, index%season+1)
+}
+
+// forecastSeries fits the model asked for, or every model when it is auto,
+// on the unbroken months at the end of y
+func forecastSeries(h history, y []float64, model string, horizon int, z float64) (Series, error) {
+	s := Series{Model: model, Candidates: []Candidate{}}
+	for t, v := range y {
+		a := Actual{Month: monthLabel(h.Start + t)}
+		if !math.IsNaN(v) {
+			n := int64(v)
+			a.Value = &n
+		}
+		s.History = append(s.History, a)
+	}
+
+	start := len(y)
+	for start > 0 && !math.IsNaN(y[start-1]) {
+		start--
+	}
+	tail := y[start:]
+
+	// Hold out up to a year, keeping enough months to fit every model
+	holdout := min(season, horizon, len(tail)-(season+3))
+
+	names := []string{model}
+	if model == ModelAuto {
+		names = Models[1:]
+	}
+	var best fit
+	bestMAE := math.Inf(1)
+	for _, name := range names {
+		f, ok := models[name](tail, horizon)
+		if !ok {
+			continue
+		}
+		c := Candidate{Model: name}
+		if holdout >= 3 {
+			c.Backtest = backtest(models[name], tail, holdout)
+		}
+		s.Candidates = append(s.Candidates, c)
+
+		// Without backtests auto keeps the first model that fits, the simplest
+		mae := math.Inf(1)
+		if c.Backtest != nil {
+			mae = c.Backtest.MAE
+		}
+		if best.Values == nil || mae < bestMAE {
+			best, bestMAE, s.Model = f, mae, name
+		}
+	}
+	if best.Values == nil {
+		return s, fmt.Errorf("%w: %d unbroken months loaded", ErrShortHistory, len(tail))
+	}
+
+	first := h.Start + len(y)
+	for i := range horizon {
+		s.Forecast = append(s.Forecast, Point{
+			Month: monthLabel(first + i),
+			Value: round(max(best.Values[i], 0)),
+			Lower: round(max(best.Values[i]-z*best.Sigma[i], 0)),
+			Upper: round(max(best.Values[i]+z*best.Sigma[i], 0)),
+		})
+	}
+
+	quarter := first - first%3
+	s.NextQuarter = outlook(h, y, best, z, quarter, quarter+3)
+	s.NextQuarter.Period = fmt.Sprintf("%04d-Q%d", quarter/season, quarter%season/3+1)
+	year := first - first%season
+	s.FullYear = outlook(h, y, best, z, year, year+season)
+	s.FullYear.Period = fmt.Sprintf("%04d", year/season)
+	return s, nil
+}
+
+// outlook adds up the months from..to (exclusive) of the history and the
+// forecast. Forecast errors are taken as independent.
+func outlook(h history, y []float64, f fit, z float64, from, to int) Outlook {
+	var o Outlook
+	var value, variance float64
+	for m := from; m < to; m++ {
+		if t := m - h.Start; t < len(y) {
+			if t >= 0 && !math.IsNaN(y[t]) {
+				o.Actual += int64(y[t])
+			}
+			continue
+		}
+		i := m - h.Start - len(y)
+		value += f.Values[i]
+		variance += f.Sigma[i] * f.Sigma[i]
+	}
+
+	actual := float64(o.Actual)
+	sigma := math.Sqrt(variance)
+	o.Value = round(actual + max(value, 0))
+	o.Lower = round(actual + max(value-z*sigma, 0))
+	o.Upper = round(actual + max(value+z*sigma, 0))
+	return o
+}
@@ -0,0 +1,170 @@
+package forecast
+
+import "math"
+
+// season is the length of the yearly cycle in months
+const season = 12
+
+// fit is a forecast of the months after a series with the standard
+// deviation of each forecast error
+type fit struct {
+	Values []float64
+	Sigma  []float64
+}
+
+// model forecasts horizon months after y, false when y is too short for it
+type model func(y []float64, horizon int) (fit, bool)
+
+var models = map[string]model{
+	ModelSeasonalNaive: seasonalNaive,
+	ModelHoltWinters:   holtWinters,
+}
+
+// seasonalNaive repeats the last year. Errors grow with every year ahead.
+func seasonalNaive(y []float64, horizon int) (fit, bool) {
+	n := len(y)
+	if n <= season {
+		return fit{}, false
+	}
+
+	var sse float64
+	for t := season; t < n; t++ {
+		e := y[t] - y[t-season]
+		sse += e * e
+	}
+	sigma := math.Sqrt(sse / float64(n-season))
+
+	f := fit{Values: make([]float64, horizon), Sigma: make([]float64, horizon)}
+	for i := range horizon {
+		f.Values[i] = y[n-season+i%season]
+		f.Sigma[i] = sigma * math.Sqrt(float64(i/season+1))
+	}
+	return f, true
+}
+
+// Smoothing parameters tried by holtWinters. Trend is kept slow, a couple
+// of years of history can't tell much about it.
+var (
+	levelGrid  = []float64{0.05, 0.15, 0.25, 0.35, 0.45, 0.55, 0.65, 0.75, 0.85, 0.95}
+	trendGrid  = []float64{0, 0.05, 0.1, 0.2, 0.3}
+	seasonGrid = []float64{0.05, 0.15, 0.25, 0.35, 0.45, 0.55, 0.65, 0.75, 0.85, 0.95}
+)
+
+// holtWinters is additive Holt-Winters with the smoothing parameters that
+// minimise the one-step errors. The first year initialises the level and
+// seasonal indices, so a year and a quarter of history is enough.
+func holtWinters(y []float64, horizon int) (fit, bool) {
+	n := len(y)
+	if n < season+3 {
+		return fit{}, false
+	}
+
+	best := math.Inf(1)
+	var alpha, beta, gamma float64
+	for _, a := range levelGrid {
+		for _, b := range trendGrid {
+			for _, g := range seasonGrid {
+				if sse, _, _, _ := smooth(y, a, b, g); sse < best {
+					best, alpha, beta, gamma = sse, a, b, g
+				}
+			}
+		}
+	}
+
+	_, level, trend, seasonal := smooth(y, alpha, beta, gamma)
+	variance := best / float64(n-season)
+
+	// Forecast error variance of the equivalent state space model, see
+	// Hyndman et al., Forecasting with Exponential Smoothing, table 6.1
+	f := fit{Values: make([]float64, horizon), Sigma: make([]float64, horizon)}
+	sum := 1.0
+	for i := range horizon {
+		f.Values[i] = level + float64(i+1)*trend + seasonal[n-season+i%season]
+		f.Sigma[i] = math.Sqrt(variance * sum)
+
+		j := float64(i + 1)
+		c := alpha * (1 + j*beta)
+		if (i+1)%season == 0 {
+			c += gamma * (1 - alpha)
+		}
+		sum += c * c
+	}
+	return f, true
+}
+
+// smooth runs Holt-Winters over y and returns the sum of squared one-step
+// errors after the first year, the final level and trend and the seasonal
+// index of every month
+func smooth(y []float64, alpha, beta, gamma float64) (sse, level, trend float64, seasonal []float64) {
+	n := len(y)
+	level = mean(y[:season])
+	if n >= 2*season {
+		trend = (mean(y[season:2*season]) - level) / season
+	}
+	seasonal = make([]float64, n)
+	for t := range season {
+		seasonal[t] = y[t] - level
+	}
+
+	for t := season; t < n; t++ {
+		e := y[t] - (level + trend + seasonal[t-season])
+		sse += e * e
+
+		previous := level
+		level = alpha*(y[t]-seasonal[t-season]) + (1-alpha)*(level+trend)
+		trend = beta*(level-previous) + (1-beta)*trend
+		seasonal[t] = gamma*(y[t]-level) + (1-gamma)*seasonal[t-season]
+	}
+	return sse, level, trend, seasonal
+}
+
+func mean(y []float64) float64 {
+	var sum float64
+	for _, v := range y {
+		sum += v
+	}
+	return sum / float64(len(y))
+}
+
+// Metrics measure a model on the last months of history it wasn't fitted
+// on. MAPE is in percent over months with registrations, nil without any.
+type Metrics struct {
+	Holdout int      `json:"holdout"`
+	MAE     float64  `json:"mae"`
+	RMSE    float64  `json:"rmse"`
+	MAPE    *float64 `json:"mape"`
+}
+
+// backtest fits a model without the last holdout months and compares its
+// forecast with them
+func backtest(m model, y []float64, holdout int) *Metrics {
+	train, test := y[:len(y)-holdout], y[len(y)-holdout:]
+	f, ok := m(train, holdout)
+	if !ok {
+		return nil
+	}
+
+	metrics := &Metrics{Holdout: holdout}
+	var absolute, squared, percent float64
+	var nonZero int
+	for i, actual := range test {
+		e := actual - max(f.Values[i], 0)
+		absolute += math.Abs(e)
+		squared += e * e
+		if actual != 0 {
+			percent += math.Abs(e / actual)
+			nonZero++
+		}
+	}
+	metrics.MAE = round(absolute / float64(holdout))
+	metrics.RMSE = round(math.Sqrt(squared / float64(holdout)))
+	if nonZero > 0 {
+		mape := round(100 * percent / float64(nonZero))
+		metrics.MAPE = &mape
+	}
+	return metrics
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
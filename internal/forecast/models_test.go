@@ -0,0 +1,148 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+// pattern is a year of monthly registrations
+var pattern = []float64{10, 4, 6, 8, 12, 14, 9, 7, 11, 13, 5, 3}
+
+// years repeats pattern for n months
+func years(n int) []float64 {
+	y := make([]float64, n)
+	for t := range y {
+		y[t] = pattern[t%season]
+	}
+	return y
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func equal(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", name, got, want)
+	}
+	for i := range want {
+		if !near(got[i], want[i]) {
+			t.Fatalf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSeasonalNaive(t *testing.T) {
+	// The second year starts 2 and 4, one-step errors 1 and 2: sigma is
+	// sqrt(5/2), times sqrt(2) in the second year ahead
+	short := append([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, 2, 4)
+	s := math.Sqrt(2.5)
+
+	tests := []struct {
+		name    string
+		y       []float64
+		horizon int
+		ok      bool
+		values  []float64
+		sigma   []float64
+	}{
+		{"one year is too short", years(12), 3, false, nil, nil},
+		{"repeats the last year", short, 14, true,
+			[]float64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 2, 4, 3, 4},
+			[]float64{s, s, s, s, s, s, s, s, s, s, s, s, s * math.Sqrt2, s * math.Sqrt2}},
+		{"exact seasons have no error", years(24), 2, true, []float64{10, 4}, []float64{0, 0}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := seasonalNaive(tc.y, tc.horizon)
+			if ok != tc.ok {
+				t.Fatalf("ok = %v, want %v", ok, tc.ok)
+			}
+			if !ok {
+				return
+			}
+			equal(t, "values", f.Values, tc.values)
+			equal(t, "sigma", f.Sigma, tc.sigma)
+		})
+	}
+}
+
+func TestHoltWinters(t *testing.T) {
+	// A repeating year is fitted exactly by any parameters: the level stays
+	// at the mean of the year, the trend at 0 and the seasonal indices at
+	// the deviations from the mean, so the forecast continues the pattern
+	tests := []struct {
+		name    string
+		y       []float64
+		horizon int
+		ok      bool
+		values  []float64
+	}{
+		{"a year and two months is too short", years(14), 3, false, nil},
+		{"a year and a quarter", years(15), 3, true, []float64{8, 12, 14}},
+		{"wraps into the next year", years(24), 14, true, append(years(12), 10, 4)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := holtWinters(tc.y, tc.horizon)
+			if ok != tc.ok {
+				t.Fatalf("ok = %v, want %v", ok, tc.ok)
+			}
+			if !ok {
+				return
+			}
+			equal(t, "values", f.Values, tc.values)
+			equal(t, "sigma", f.Sigma, make([]float64, tc.horizon))
+		})
+	}
+}
+
+func TestSmooth(t *testing.T) {
+	// The level starts at the mean of the first year, 8.5, and January is
+	// 1.5 above it, so 10 is expected and 28 is 18 off. With alpha 1 the
+	// level jumps to 28 - 1.5, with gamma 0 the seasonal index stays.
+	y := append(years(12), 28)
+	sse, level, trend, seasonal := smooth(y, 1, 0, 0)
+	if !near(sse, 18*18) || !near(level, 26.5) || !near(trend, 0) {
+		t.Errorf("sse, level, trend = %v, %v, %v, want 324, 26.5, 0", sse, level, trend)
+	}
+	if !near(seasonal[12], 1.5) {
+		t.Errorf("seasonal = %v", seasonal)
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	mape := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		y       []float64
+		holdout int
+		want    *Metrics
+	}{
+		{"history too short for the model", years(13), 2, nil},
+		// Forecasts 10 and 4 against 13 and 0: errors 3 and 4, MAPE only
+		// over the month with registrations, 3/13
+		{"errors", append(years(24), 13, 0), 2, &Metrics{Holdout: 2, MAE: 3.5, RMSE: 3.54, MAPE: mape(23.08)}},
+		{"no registrations in the holdout", append(years(24), 0, 0), 2, &Metrics{Holdout: 2, MAE: 7, RMSE: 7.62}},
+		{"exact", years(27), 3, &Metrics{Holdout: 3, MAPE: mape(0)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := backtest(seasonalNaive, tc.y, tc.holdout)
+			if got == nil || tc.want == nil {
+				if got != tc.want {
+					t.Fatalf("backtest = %+v, want %+v", got, tc.want)
+				}
+				return
+			}
+			if got.Holdout != tc.want.Holdout || got.MAE != tc.want.MAE || got.RMSE != tc.want.RMSE {
+				t.Errorf("backtest = %+v, want %+v", got, tc.want)
+			}
+			if (got.MAPE == nil) != (tc.want.MAPE == nil) || got.MAPE != nil && *got.MAPE != *tc.want.MAPE {
+				t.Errorf("MAPE = %v, want %v", got.MAPE, tc.want.MAPE)
+			}
+		})
+	}
+}
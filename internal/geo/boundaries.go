@@ -0,0 +1,72 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ErrNoBoundaries means no region polygons are configured. Only polygons
+// can be shaded on a map, so GeoJSON output is unavailable without them.
+var ErrNoBoundaries = errors.New("region boundaries are not configured, set REGION_BOUNDARIES_FILE")
+
+// Boundaries maps region codes to GeoJSON geometries read from
+// REGION_BOUNDARIES_FILE, a FeatureCollection of region polygons. Features
+// are matched on a "code", "iso_3166_2", "shapeISO" or "name" property.
+// Boundaries aren't bundled, the file is picked for the level of detail
+// the maps need.
+var Boundaries = sync.OnceValues(func() (map[string]json.RawMessage, error) {
+	path := os.Getenv("REGION_BOUNDARIES_FILE")
+	if path == "" {
+		return nil, ErrNoBoundaries
+	}
+	boundaries, err := loadBoundaries(path)
+	if err != nil {
+		slog.Error("Can't load region boundaries", "file", path, "error", err)
+		return nil, fmt.Errorf("%w: %s: %w", ErrNoBoundaries, path, err)
+	}
+	slog.Info("Loaded region boundaries", "file", path, "regions", len(boundaries))
+	return boundaries, nil
+})
+
+func loadBoundaries(path string) (map[string]json.RawMessage, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection struct {
+		Features []struct {
+			Properties map[string]any  `json:"properties"`
+			Geometry   json.RawMessage `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(raw, &collection); err != nil {
+		return nil, err
+	}
+
+	result := map[string]json.RawMessage{}
+	for i, f := range collection.Features {
+		var region Region
+		var ok bool
+		for _, key := range []string{"code", "iso_3166_2", "shapeISO", "name", "name_en"} {
+			if v, isString := f.Properties[key].(string); isString {
+				if region, ok = FindRegion(v); ok {
+					break
+				}
+			}
+		}
+		if !ok {
+			slog.Warn("Boundary feature matches no region", "file", path, "feature", i)
+			continue
+		}
+		result[region.Code] = f.Geometry
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no features match a region")
+	}
+	return result, nil
+}
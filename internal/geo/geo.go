@@ -0,0 +1,204 @@
+package geo
+
+import (
+	"strings"
+)
+
+// District is a federal district of Russia. Districts have no ISO codes,
+// Code is our own and stays stable.
+type District struct {
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	NameEn string `json:"name_en"`
+	Short  string `json:"short"`
+}
+
+// Region is a federal subject. Code is the ISO 3166-2:RU code; subjects
+// missing from the standard use the codes common in Russian sources.
+// OKATO is the first level OKATO code, empty when none was assigned.
+type Region struct {
+	Code     string `json:"code"`
+	OKATO    string `json:"okato,omitempty"`
+	Name     string `json:"name"`
+	NameEn   string `json:"name_en"`
+	District string `json:"district"`
+	// Aliases are other spellings met in registration data
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+const (
+	central      = "CEN"
+	northwestern = "NW"
+	southern     = "SOU"
+	northCaucas  = "NCA"
+	volga        = "VOL"
+	ural         = "URA"
+	siberian     = "SIB"
+	farEastern   = "FE"
+)
+
+var Districts = []District{
+	{central, "Центральный федеральный округ", "Central Federal District", "ЦФО"},
+	{northwestern, "Северо-Западный федеральный округ", "Northwestern Federal District", "СЗФО"},
+	{southern, "Южный федеральный округ", "Southern Federal District", "ЮФО"},
+	{northCaucas, "Северо-Кавказский федеральный округ", "North Caucasian Federal District", "СКФО"},
+	{volga, "Приволжский федеральный округ", "Volga Federal District", "ПФО"},
+	{ural, "Уральский федеральный округ", "Ural Federal District", "УФО"},
+	{siberian, "Сибирский федеральный округ", "Siberian Federal District", "СФО"},
+	{farEastern, "Дальневосточный федеральный округ", "Far Eastern Federal District", "ДФО"},
+}
+
+var Regions = []Region{
+	{Code: "RU-BEL", OKATO: "14", Name: "Белгородская область", NameEn: "Belgorod Oblast", District: central},
+	{Code: "RU-BRY", OKATO: "15", Name: "Брянская область", NameEn: "Bryansk Oblast", District: central},
+	{Code: "RU-VLA", OKATO: "17", Name: "Владимирская область", NameEn: "Vladimir Oblast", District: central},
+	{Code: "RU-VOR", OKATO: "20", Name: "Воронежская область", NameEn: "Voronezh Oblast", District: central},
+	{Code: "RU-IVA", OKATO: "24", Name: "Ивановская область", NameEn: "Ivanovo Oblast", District: central},
+	{Code: "RU-KLU", OKATO: "29", Name: "Калужская область", NameEn: "Kaluga Oblast", District: central},
+	{Code: "RU-KOS", OKATO: "34", Name: "Костромская область", NameEn: "Kostroma Oblast", District: central},
+	{Code: "RU-KRS", OKATO: "38", Name: "Курская область", NameEn: "Kursk Oblast", District: central},
+	{Code: "RU-LIP", OKATO: "42", Name: "Липецкая область", NameEn: "Lipetsk Oblast", District: central},
+	{Code: "RU-MOS", OKATO: "46", Name: "Московская область", NameEn: "Moscow Oblast", District: central},
+	{Code: "RU-ORL", OKATO: "54", Name: "Орловская область", NameEn: "Oryol Oblast", District: central},
+	{Code: "RU-RYA", OKATO: "61", Name: "Рязанская область", NameEn: "Ryazan Oblast", District: central},
+	{Code: "RU-SMO", OKATO: "66", Name: "Смоленская область", NameEn: "Smolensk Oblast", District: central},
+	{Code: "RU-TAM", OKATO: "68", Name: "Тамбовская область", NameEn: "Tambov Oblast", District: central},
+	{Code: "RU-TVE", OKATO: "28", Name: "Тверская область", NameEn: "Tver Oblast", District: central},
+	{Code: "RU-TUL", OKATO: "70", Name: "Тульская область", NameEn: "Tula Oblast", District: central},
+	{Code: "RU-YAR", OKATO: "78", Name: "Ярославская область", NameEn: "Yaroslavl Oblast", District: central},
+	{Code: "RU-MOW", OKATO: "45", Name: "Москва", NameEn: "Moscow", District: central, Aliases: []string{"г. Москва", "город Москва"}},
+
+	{Code: "RU-KR", OKATO: "86", Name: "Республика Карелия", NameEn: "Republic of Karelia", District: northwestern, Aliases: []string{"Карелия"}},
+	{Code: "RU-KO", OKATO: "87", Name: "Республика Коми", NameEn: "Komi Republic", District: northwestern, Aliases: []string{"Коми"}},
+	{Code: "RU-ARK", OKATO: "11", Name: "Архангельская область", NameEn: "Arkhangelsk Oblast", District: northwestern},
+	{Code: "RU-NEN", OKATO: "11100", Name: "Ненецкий автономный округ", NameEn: "Nenets Autonomous Okrug", District: northwestern, Aliases: []string{"Ненецкий АО"}},
+	{Code: "RU-VLG", OKATO: "19", Name: "Вологодская область", NameEn: "Vologda Oblast", District: northwestern},
+	{Code: "RU-KGD", OKATO: "27", Name: "Калининградская область", NameEn: "Kaliningrad Oblast", District: northwestern},
+	{Code: "RU-LEN", OKATO: "41", Name: "Ленинградская область", NameEn: "Leningrad Oblast", District: northwestern},
+	{Code: "RU-MUR", OKATO: "47", Name: "Мурманская область", NameEn: "Murmansk Oblast", District: northwestern},
+	{Code: "RU-NGR", OKATO: "49", Name: "Новгородская область", NameEn: "Novgorod Oblast", District: northwestern},
+	{Code: "RU-PSK", OKATO: "58", Name: "Псковская область", NameEn: "Pskov Oblast", District: northwestern},
+	{Code: "RU-SPE", OKATO: "40", Name: "Санкт-Петербург", NameEn: "Saint Petersburg", District: northwestern, Aliases: []string{"г. Санкт-Петербург", "город Санкт-Петербург"}},
+
+	{Code: "RU-AD", OKATO: "79", Name: "Республика Адыгея", NameEn: "Republic of Adygea", District: southern, Aliases: []string{"Адыгея"}},
+	{Code: "RU-KL", OKATO: "85", Name: "Республика Калмыкия", NameEn: "Republic of Kalmykia", District: southern, Aliases: []string{"Калмыкия"}},
+	{Code: "RU-CR", OKATO: "35", Name: "Республика Крым", NameEn: "Republic of Crimea", District: southern, Aliases: []string{"Крым"}},
+	{Code: "RU-KDA", OKATO: "03", Name: "Краснодарский край", NameEn: "Krasnodar Krai", District: southern},
+	{Code: "RU-AST", OKATO: "12", Name: "Астраханская область", NameEn: "Astrakhan Oblast", District: southern},
+	{Code: "RU-VGG", OKATO: "18", Name: "Волгоградская область", NameEn: "Volgograd Oblast", District: southern},
+	{Code: "RU-ROS", OKATO: "60", Name: "Ростовская область", NameEn: "Rostov Oblast", District: southern},
+	{Code: "RU-SEV", OKATO: "67", Name: "Севастополь", NameEn: "Sevastopol", District: southern, Aliases: []string{"г. Севастополь", "город Севастополь"}},
+	{Code: "RU-DPR", Name: "Донецкая Народная Республика", NameEn: "Donetsk People's Republic", District: southern, Aliases: []string{"ДНР"}},
+	{Code: "RU-LPR", Name: "Луганская Народная Республика", NameEn: "Luhansk People's Republic", District: southern, Aliases: []string{"ЛНР"}},
+	{Code: "RU-ZAP", Name: "Запорожская область", NameEn: "Zaporozhye Oblast", District: southern},
+	{Code: "RU-KHE", Name: "Херсонская область", NameEn: "Kherson Oblast", District: southern},
+
+	{Code: "RU-DA", OKATO: "82", Name: "Республика Дагестан", NameEn: "Republic of Dagestan", District: northCaucas, Aliases: []string{"Дагестан"}},
+	{Code: "RU-IN", OKATO: "26", Name: "Республика Ингушетия", NameEn: "Republic of Ingushetia", District: northCaucas, Aliases: []string{"Ингушетия"}},
+	{Code: "RU-KB", OKATO: "83", Name: "Кабардино-Балкарская Республика", NameEn: "Kabardino-Balkar Republic", District: northCaucas, Aliases: []string{"Кабардино-Балкария"}},
+	{Code: "RU-KC", OKATO: "91", Name: "Карачаево-Черкесская Республика", NameEn: "Karachay-Cherkess Republic", District: northCaucas, Aliases: []string{"Карачаево-Черкесия"}},
+	{Code: "RU-SE", OKATO: "90", Name: "Республика Северная Осетия — Алания", NameEn: "Republic of North Ossetia–Alania", District: northCaucas, Aliases: []string{"Северная Осетия", "Республика Северная Осетия"}},
+	{Code: "RU-CE", OKATO: "96", Name: "Чеченская Республика", NameEn: "Chechen Republic", District: northCaucas, Aliases: []string{"Чечня", "Чеченская Республика (Чечня)"}},
+	{Code: "RU-STA", OKATO: "07", Name: "Ставропольский край", NameEn: "Stavropol Krai", District: northCaucas},
+
+	{Code: "RU-BA", OKATO: "80", Name: "Республика Башкортостан", NameEn: "Republic of Bashkortostan", District: volga, Aliases: []string{"Башкортостан", "Башкирия"}},
+	{Code: "RU-ME", OKATO: "88", Name: "Республика Марий Эл", NameEn: "Mari El Republic", District: volga, Aliases: []string{"Марий Эл"}},
+	{Code: "RU-MO", OKATO: "89", Name: "Республика Мордовия", NameEn: "Republic of Mordovia", District: volga, Aliases: []string{"Мордовия"}},
+	{Code: "RU-TA", OKATO: "92", Name: "Республика Татарстан", NameEn: "Republic of Tatarstan", District: volga, Aliases: []string{"Татарстан"}},
+	{Code: "RU-UD", OKATO: "94", Name: "Удмуртская Республика", NameEn: "Udmurt Republic", District: volga, Aliases: []string{"Удмуртия"}},
+	{Code: "RU-CU", OKATO: "97", Name: "Чувашская Республика", NameEn: "Chuvash Republic", District: volga, Aliases: []string{"Чувашия", "Чувашская Республика — Чувашия"}},
+	{Code: "RU-PER", OKATO: "57", Name: "Пермский край", NameEn: "Perm Krai", District: volga},
+	{Code: "RU-KIR", OKATO: "33", Name: "Кировская область", NameEn: "Kirov Oblast", District: volga},
+	{Code: "RU-NIZ", OKATO: "22", Name: "Нижегородская область", NameEn: "Nizhny Novgorod Oblast", District: volga},
+	{Code: "RU-ORE", OKATO: "53", Name: "Оренбургская область", NameEn: "Orenburg Oblast", District: volga},
+	{Code: "RU-PNZ", OKATO: "56", Name: "Пензенская область", NameEn: "Penza Oblast", District: volga},
+	{Code: "RU-SAM", OKATO: "36", Name: "Самарская область", NameEn: "Samara Oblast", District: volga},
+	{Code: "RU-SAR", OKATO: "63", Name: "Саратовская область", NameEn: "Saratov Oblast", District: volga},
+	{Code: "RU-ULY", OKATO: "73", Name: "Ульяновская область", NameEn: "Ulyanovsk Oblast", District: volga},
+
+	{Code: "RU-KGN", OKATO: "37", Name: "Курганская область", NameEn: "Kurgan Oblast", District: ural},
+	{Code: "RU-SVE", OKATO: "65", Name: "Свердловская область", NameEn: "Sverdlovsk Oblast", District: ural},
+	{Code: "RU-TYU", OKATO: "71", Name: "Тюменская область", NameEn: "Tyumen Oblast", District: ural},
+	{Code: "RU-CHE", OKATO: "75", Name: "Челябинская область", NameEn: "Chelyabinsk Oblast", District: ural},
+	{Code: "RU-KHM", OKATO: "71100", Name: "Ханты-Мансийский автономный округ — Югра", NameEn: "Khanty-Mansi Autonomous Okrug – Yugra", District: ural, Aliases: []string{"Ханты-Мансийский автономный округ", "ХМАО", "ХМАО — Югра"}},
+	{Code: "RU-YAN", OKATO: "71140", Name: "Ямало-Ненецкий автономный округ", NameEn: "Yamalo-Nenets Autonomous Okrug", District: ural, Aliases: []string{"ЯНАО", "Ямало-Ненецкий АО"}},
+
+	{Code: "RU-AL", OKATO: "84", Name: "Республика Алтай", NameEn: "Altai Republic", District: siberian},
+	{Code: "RU-TY", OKATO: "93", Name: "Республика Тыва", NameEn: "Tuva Republic", District: siberian, Aliases: []string{"Тыва", "Тува"}},
+	{Code: "RU-KK", OKATO: "95", Name: "Республика Хакасия", NameEn: "Republic of Khakassia", District: siberian, Aliases: []string{"Хакасия"}},
+	{Code: "RU-ALT", OKATO: "01", Name: "Алтайский край", NameEn: "Altai Krai", District: siberian},
+	{Code: "RU-KYA", OKATO: "04", Name: "Красноярский край", NameEn: "Krasnoyarsk Krai", District: siberian},
+	{Code: "RU-IRK", OKATO: "25", Name: "Иркутская область", NameEn: "Irkutsk Oblast", District: siberian},
+	{Code: "RU-KEM", OKATO: "32", Name: "Кемеровская область", NameEn: "Kemerovo Oblast", District: siberian, Aliases: []string{"Кемеровская область — Кузбасс", "Кузбасс"}},
+	{Code: "RU-NVS", OKATO: "50", Name: "Новосибирская область", NameEn: "Novosibirsk Oblast", District: siberian},
+	{Code: "RU-OMS", OKATO: "52", Name: "Омская область", NameEn: "Omsk Oblast", District: siberian},
+	{Code: "RU-TOM", OKATO: "69", Name: "Томская область", NameEn: "Tomsk Oblast", District: siberian},
+
+	{Code: "RU-BU", OKATO: "81", Name: "Республика Бурятия", NameEn: "Republic of Buryatia", District: farEastern, Aliases: []string{"Бурятия"}},
+	{Code: "RU-SA", OKATO: "98", Name: "Республика Саха (Якутия)", NameEn: "Sakha Republic (Yakutia)", District: farEastern, Aliases: []string{"Якутия", "Республика Саха"}},
+	{Code: "RU-ZAB", OKATO: "76", Name: "Забайкальский край", NameEn: "Zabaykalsky Krai", District: farEastern},
+	{Code: "RU-KAM", OKATO: "30", Name: "Камчатский край", NameEn: "Kamchatka Krai", District: farEastern},
+	{Code: "RU-PRI", OKATO: "05", Name: "Приморский край", NameEn: "Primorsky Krai", District: farEastern},
+	{Code: "RU-KHA", OKATO: "08", Name: "Хабаровский край", NameEn: "Khabarovsk Krai", District: farEastern},
+	{Code: "RU-AMU", OKATO: "10", Name: "Амурская область", NameEn: "Amur Oblast", District: farEastern},
+	{Code: "RU-MAG", OKATO: "44", Name: "Магаданская область", NameEn: "Magadan Oblast", District: farEastern},
+	{Code: "RU-SAK", OKATO: "64", Name: "Сахалинская область", NameEn: "Sakhalin Oblast", District: farEastern},
+	{Code: "RU-YEV", OKATO: "99", Name: "Еврейская автономная область", NameEn: "Jewish Autonomous Oblast", District: farEastern, Aliases: []string{"Еврейская АО"}},
+	{Code: "RU-CHU", OKATO: "77", Name: "Чукотский автономный округ", NameEn: "Chukotka Autonomous Okrug", District: farEastern, Aliases: []string{"Чукотский АО", "Чукотка"}},
+}
+
+var (
+	districtIndex = map[string]*District{}
+	regionIndex   = map[string]*Region{}
+)
+
+func init() {
+	for i := range Districts {
+		d := &Districts[i]
+		districtIndex[normalize(d.Code)] = d
+		districtIndex[normalize(d.Name)] = d
+		districtIndex[normalize(d.NameEn)] = d
+		districtIndex[normalize(d.Short)] = d
+		// "Центральный ФО" and plain "Центральный"
+		base := strings.TrimSuffix(d.Name, " федеральный округ")
+		districtIndex[normalize(base)] = d
+		districtIndex[normalize(base+" ФО")] = d
+	}
+	for i := range Regions {
+		r := &Regions[i]
+		regionIndex[normalize(r.Code)] = r
+		regionIndex[normalize(r.Name)] = r
+		regionIndex[normalize(r.NameEn)] = r
+		for _, a := range r.Aliases {
+			regionIndex[normalize(a)] = r
+		}
+	}
+}
+
+// FindDistrict resolves a federal district by code, Russian, English or
+// short name
+func FindDistrict(name string) (District, bool) {
+	d, ok := districtIndex[normalize(name)]
+	if !ok {
+		return District{}, false
+	}
+	return *d, true
+}
+
+// FindRegion resolves a region by code, Russian or English name or alias
+func FindRegion(name string) (Region, bool) {
+	r, ok := regionIndex[normalize(name)]
+	if !ok {
+		return Region{}, false
+	}
+	return *r, true
+}
+
+// normalize folds case, ё and the different dashes and spaces used in
+// official names
+func normalize(s string) string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("ё", "е", "—", "-", "–", "-", "г.", "г. ").Replace(s)
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(strings.ReplaceAll(s, " -", "-"), "- ", "-")
+}
@@ -0,0 +1,61 @@
+package geo
+
+import (
+	"context"
+	"log/slog"
+	"truck-analytics-platform/internal/db"
+)
+
+// Sync writes the directory to the federal_districts, regions and
+// region_aliases tables so that SQL can join registration data on codes
+func Sync(ctx context.Context, conn db.DB) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM region_aliases`); err != nil {
+		return err
+	}
+
+	for _, d := range Districts {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO federal_districts (code, name, name_en, short) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (code) DO UPDATE SET name = $2, name_en = $3, short = $4
+		`, d.Code, d.Name, d.NameEn, d.Short)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range Regions {
+		var okato *string
+		if r.OKATO != "" {
+			okato = &r.OKATO
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO regions (code, okato, name, name_en, district) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (code) DO UPDATE SET okato = $2, name = $3, name_en = $4, district = $5
+		`, r.Code, okato, r.Name, r.NameEn, r.District)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, d := range districtIndex {
+		if _, err := tx.Exec(ctx, `INSERT INTO region_aliases (key, district) VALUES ($1, $2)`, key, d.Code); err != nil {
+			return err
+		}
+	}
+	for key, r := range regionIndex {
+		if _, err := tx.Exec(ctx, `INSERT INTO region_aliases (key, region) VALUES ($1, $2)`, key, r.Code); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.Info("Synced region directory", "districts", len(Districts), "regions", len(Regions))
+	return nil
+}
@@ -0,0 +1,106 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/reports"
+)
+
+// Costs charged against the complexity budget of a request. Running a
+// report query is what's expensive; region lists are charged too, as
+// districts and regions nest into each other without end.
+const (
+	costReport  = 10
+	costRegions = 1
+)
+
+// request holds what the resolvers of one query share: the connection, the
+// key permissions, the complexity budget and the reports already run, so
+// that nested fields asking for the same report query the database once
+type request struct {
+	conn        db.DB
+	permissions auth.Permissions
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	datasets  []db.Dataset
+	reports   map[string]*reportResult
+}
+
+type reportResult struct {
+	once sync.Once
+	data *reportData
+	err  error
+}
+
+type contextKey struct{}
+
+func withRequest(ctx context.Context, r *request) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+func fromContext(ctx context.Context) *request {
+	return ctx.Value(contextKey{}).(*request)
+}
+
+// loadDatasets lists the registration tables once per request
+func (r *request) loadDatasets(ctx context.Context) ([]db.Dataset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.datasets != nil {
+		return r.datasets, nil
+	}
+	datasets, err := db.Datasets(ctx, r.conn)
+	if err != nil {
+		return nil, err
+	}
+	r.datasets = datasets
+	return datasets, nil
+}
+
+// report runs a report once per request, charging the budget the first time
+func (r *request) report(ctx context.Context, q reports.Query) (*reportData, error) {
+	key := q.Name() + "/" + fmt.Sprint(q.Brands)
+
+	r.mu.Lock()
+	result, ok := r.reports[key]
+	if !ok {
+		if err := r.spendLocked(costReport); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		result = &reportResult{}
+		r.reports[key] = result
+	}
+	r.mu.Unlock()
+
+	result.once.Do(func() {
+		data, err := reports.Run(ctx, r.conn, q)
+		if err != nil {
+			result.err = err
+			return
+		}
+		result.data = newReportData(q.Brands, data)
+	})
+	return result.data, result.err
+}
+
+// spend charges the budget, failing once the query went over it
+func (r *request) spend(cost int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spendLocked(cost)
+}
+
+func (r *request) spendLocked(cost int) error {
+	if r.remaining < cost {
+		return fmt.Errorf("query exceeds the complexity limit of %d: a report costs %d, a list of regions %d",
+			r.limit, costReport, costRegions)
+	}
+	r.remaining -= cost
+	return nil
+}
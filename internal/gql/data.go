@@ -0,0 +1,67 @@
+package gql
+
+import (
+	"cmp"
+	"slices"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/reports"
+)
+
+// reportData is a report regrouped for the resolvers
+type reportData struct {
+	districts []districtVolume
+	brands    []reports.BrandVolume
+	total     int
+}
+
+type districtVolume struct {
+	name    string
+	total   reports.TruckAnalytics
+	regions []reports.TruckAnalytics
+}
+
+// newReportData splits the district total rows from the regions and sums
+// the brands over the country. Districts follow the directory order.
+func newReportData(brands []string, data map[string][]reports.TruckAnalytics) *reportData {
+	r := &reportData{brands: make([]reports.BrandVolume, len(brands))}
+	for i, b := range brands {
+		r.brands[i].Brand = b
+	}
+
+	for name, rows := range data {
+		d := districtVolume{name: name}
+		for _, row := range rows {
+			if row.RegionName == name {
+				d.total = row
+			} else {
+				d.regions = append(d.regions, row)
+			}
+		}
+		r.districts = append(r.districts, d)
+		r.total += d.total.Total
+
+		// Rows have the brand columns of the query, in its order
+		for i, b := range d.total.Brands {
+			if b.Quantity == nil {
+				continue
+			}
+			if r.brands[i].Quantity == nil {
+				r.brands[i].Quantity = new(int)
+			}
+			*r.brands[i].Quantity += *b.Quantity
+		}
+	}
+
+	slices.SortFunc(r.districts, func(a, b districtVolume) int {
+		return cmp.Or(cmp.Compare(districtOrder(a.name), districtOrder(b.name)), cmp.Compare(a.name, b.name))
+	})
+	return r
+}
+
+func districtOrder(name string) int {
+	d, ok := geo.FindDistrict(name)
+	if !ok {
+		return len(geo.Districts)
+	}
+	return slices.IndexFunc(geo.Districts, func(x geo.District) bool { return x.Code == d.Code })
+}
@@ -0,0 +1,80 @@
+// Package gql serves segment analytics over GraphQL. Reports are resolved
+// with the report query layer; a complexity budget limits how many a query
+// may run and the schema limits nesting depth.
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"os"
+	"strconv"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/errors"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+type Config struct {
+	// MaxDepth is the deepest field nesting a query may have
+	MaxDepth int
+	// MaxComplexity is the budget of a query, see costReport and costRegions
+	MaxComplexity int
+	// MaxQueryBytes is the longest query text accepted
+	MaxQueryBytes int
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{MaxDepth: 10, MaxComplexity: 100, MaxQueryBytes: 16 << 10}
+	if n, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_DEPTH")); err == nil && n > 0 {
+		cfg.MaxDepth = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_COMPLEXITY")); err == nil && n > 0 {
+		cfg.MaxComplexity = n
+	}
+	return cfg
+}
+
+// Params is a GraphQL request
+type Params struct {
+	Query         string         `json:"query" form:"query" binding:"required"`
+	OperationName string         `json:"operationName" form:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Server struct {
+	cfg    Config
+	schema *graphql.Schema
+}
+
+func New(cfg Config) *Server {
+	schema := graphql.MustParseSchema(schemaSDL, &queryResolver{},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(cfg.MaxDepth),
+	)
+	return &Server{cfg: cfg, schema: schema}
+}
+
+// Schema returns the schema in SDL
+func Schema() string {
+	return schemaSDL
+}
+
+// Exec runs a query with the permissions of the API key
+func (s *Server) Exec(ctx context.Context, conn db.DB, p auth.Permissions, params Params) *graphql.Response {
+	if len(params.Query) > s.cfg.MaxQueryBytes {
+		return &graphql.Response{Errors: []*errors.QueryError{errors.Errorf("query is longer than %d bytes", s.cfg.MaxQueryBytes)}}
+	}
+
+	ctx = withRequest(ctx, &request{
+		conn:        conn,
+		permissions: p,
+		limit:       s.cfg.MaxComplexity,
+		remaining:   s.cfg.MaxComplexity,
+		reports:     map[string]*reportResult{},
+	})
+	return s.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+}
@@ -0,0 +1,354 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/segments"
+)
+
+var errNoAccess = errors.New("API key has no access to this report")
+
+type queryResolver struct{}
+
+func (*queryResolver) Segments() []*segmentResolver {
+	result := make([]*segmentResolver, len(segments.All))
+	for i, s := range segments.All {
+		result[i] = &segmentResolver{s}
+	}
+	return result
+}
+
+func (*queryResolver) Segment(args struct{ Name string }) *segmentResolver {
+	s, ok := segments.Get(args.Name)
+	if !ok {
+		return nil
+	}
+	return &segmentResolver{s}
+}
+
+// Periods lists the datasets of the years the key may query
+func (*queryResolver) Periods(ctx context.Context) ([]*periodResolver, error) {
+	r := fromContext(ctx)
+	datasets, err := r.loadDatasets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*periodResolver{}
+	for _, d := range datasets {
+		if len(r.permissions.Years) == 0 || slices.Contains(r.permissions.Years, d.Year) {
+			result = append(result, &periodResolver{d})
+		}
+	}
+	return result, nil
+}
+
+func (*queryResolver) Districts() []*districtResolver {
+	result := make([]*districtResolver, len(geo.Districts))
+	for i, d := range geo.Districts {
+		result[i] = &districtResolver{d}
+	}
+	return result
+}
+
+func (*queryResolver) District(args struct{ Code string }) *districtResolver {
+	d, ok := geo.FindDistrict(args.Code)
+	if !ok {
+		return nil
+	}
+	return &districtResolver{d}
+}
+
+func (*queryResolver) Regions(ctx context.Context, args struct{ District *string }) ([]*regionResolver, error) {
+	if err := fromContext(ctx).spend(costRegions); err != nil {
+		return nil, err
+	}
+	result := []*regionResolver{}
+	var district geo.District
+	if args.District != nil {
+		var ok bool
+		if district, ok = geo.FindDistrict(*args.District); !ok {
+			return result, nil
+		}
+	}
+	for _, r := range geo.Regions {
+		if args.District == nil || r.District == district.Code {
+			result = append(result, &regionResolver{r})
+		}
+	}
+	return result, nil
+}
+
+func (*queryResolver) Region(args struct{ Code string }) *regionResolver {
+	r, ok := geo.FindRegion(args.Code)
+	if !ok {
+		return nil
+	}
+	return &regionResolver{r}
+}
+
+type segmentResolver struct {
+	s segments.Segment
+}
+
+func (r *segmentResolver) Name() string         { return r.s.Name }
+func (r *segmentResolver) WheelFormula() string { return r.s.WheelFormula }
+func (r *segmentResolver) BodyType() string     { return r.s.BodyType }
+func (r *segmentResolver) Brands() []string     { return r.s.Brands }
+
+func (r *segmentResolver) Report(ctx context.Context, args struct {
+	Dataset *string
+	Year    *int32
+	Months  *int32
+}) (*reportResolver, error) {
+	datasets, err := fromContext(ctx).loadDatasets(ctx)
+	if err != nil || len(datasets) == 0 {
+		return nil, err
+	}
+	months := int(deref(args.Months))
+
+	dataset, err := db.SelectDataset(datasets, deref(args.Dataset), int(deref(args.Year)), months)
+	if err != nil {
+		return nil, nil
+	}
+	return newReport(ctx, dataset, r.s, months)
+}
+
+type periodResolver struct {
+	d db.Dataset
+}
+
+func (r *periodResolver) Dataset() string  { return r.d.Table }
+func (r *periodResolver) Year() int32      { return int32(r.d.Year) }
+func (r *periodResolver) FromMonth() int32 { return int32(r.d.FromMonth) }
+func (r *periodResolver) ToMonth() int32   { return int32(r.d.ToMonth) }
+
+// Reports runs the segments the key may query
+func (r *periodResolver) Reports(ctx context.Context, args struct{ Months *int32 }) ([]*reportResolver, error) {
+	p := fromContext(ctx).permissions
+	result := []*reportResolver{}
+	for _, s := range segments.All {
+		if !p.AllowsReport(s.Name, r.d.Year) || len(p.AllowedBrands(s.Brands)) == 0 {
+			continue
+		}
+		report, err := newReport(ctx, r.d, s, int(deref(args.Months)))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, report)
+	}
+	return result, nil
+}
+
+func (r *periodResolver) Report(ctx context.Context, args struct {
+	Segment string
+	Months  *int32
+}) (*reportResolver, error) {
+	s, ok := segments.Get(args.Segment)
+	if !ok {
+		return nil, nil
+	}
+	return newReport(ctx, r.d, s, int(deref(args.Months)))
+}
+
+type reportResolver struct {
+	q       reports.Query
+	dataset db.Dataset
+	data    *reportData
+}
+
+// newReport runs the report of a segment in a dataset, narrowed to the
+// brands the key may see
+func newReport(ctx context.Context, d db.Dataset, s segments.Segment, months int) (*reportResolver, error) {
+	r := fromContext(ctx)
+	brands := r.permissions.AllowedBrands(s.Brands)
+	if !r.permissions.AllowsReport(s.Name, d.Year) || len(brands) == 0 {
+		return nil, errNoAccess
+	}
+
+	q := reports.Query{Dataset: d.Table, Segment: s.Name, Months: months, Brands: brands}
+	data, err := r.report(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return &reportResolver{q: q, dataset: d, data: data}, nil
+}
+
+func (r *reportResolver) Dataset() string  { return r.q.Dataset }
+func (r *reportResolver) Segment() string  { return r.q.Segment }
+func (r *reportResolver) Year() int32      { return int32(r.dataset.Year) }
+func (r *reportResolver) ToMonth() int32   { return int32(r.toMonth()) }
+func (r *reportResolver) Title() string    { return r.q.Title() }
+func (r *reportResolver) Brands() []string { return r.q.Brands }
+func (r *reportResolver) Total() int32     { return int32(r.data.total) }
+
+func (r *reportResolver) BrandTotals() []*brandVolumeResolver {
+	return brandVolumes(r.data.brands)
+}
+
+func (r *reportResolver) Districts() []*districtVolumeResolver {
+	result := make([]*districtVolumeResolver, len(r.data.districts))
+	for i := range r.data.districts {
+		result[i] = &districtVolumeResolver{&r.data.districts[i]}
+	}
+	return result
+}
+
+func (r *reportResolver) District(args struct{ Code string }) *districtVolumeResolver {
+	want, ok := geo.FindDistrict(args.Code)
+	if !ok {
+		return nil
+	}
+	for i, d := range r.data.districts {
+		if found, ok := geo.FindDistrict(d.name); ok && found.Code == want.Code {
+			return &districtVolumeResolver{&r.data.districts[i]}
+		}
+	}
+	return nil
+}
+
+// PreviousYear is the report of the same months a year earlier, when a
+// dataset covers them and the key may query it
+func (r *reportResolver) PreviousYear(ctx context.Context) (*reportResolver, error) {
+	req := fromContext(ctx)
+	datasets, err := req.loadDatasets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	months := r.toMonth()
+	previous, ok := db.PickDataset(datasets, r.dataset.Year-1, months)
+	if !ok || previous.ToMonth < months || !req.permissions.AllowsReport(r.q.Segment, previous.Year) {
+		return nil, nil
+	}
+	s, _ := segments.Get(r.q.Segment)
+	return newReport(ctx, previous, s, months)
+}
+
+func (r *reportResolver) toMonth() int {
+	if r.q.Months > 0 && r.q.Months < r.dataset.ToMonth {
+		return r.q.Months
+	}
+	return r.dataset.ToMonth
+}
+
+type districtVolumeResolver struct {
+	d *districtVolume
+}
+
+func (r *districtVolumeResolver) Name() string { return r.d.name }
+func (r *districtVolumeResolver) Total() int32 { return int32(r.d.total.Total) }
+
+func (r *districtVolumeResolver) District() *districtResolver {
+	d, ok := geo.FindDistrict(r.d.name)
+	if !ok {
+		return nil
+	}
+	return &districtResolver{d}
+}
+
+func (r *districtVolumeResolver) Brands() []*brandVolumeResolver {
+	return brandVolumes(r.d.total.Brands)
+}
+
+func (r *districtVolumeResolver) Regions() []*regionVolumeResolver {
+	result := make([]*regionVolumeResolver, len(r.d.regions))
+	for i, region := range r.d.regions {
+		result[i] = &regionVolumeResolver{region}
+	}
+	return result
+}
+
+type regionVolumeResolver struct {
+	r reports.TruckAnalytics
+}
+
+func (r *regionVolumeResolver) Name() string { return r.r.RegionName }
+func (r *regionVolumeResolver) Total() int32 { return int32(r.r.Total) }
+
+func (r *regionVolumeResolver) Region() *regionResolver {
+	region, ok := geo.FindRegion(r.r.RegionName)
+	if !ok {
+		return nil
+	}
+	return &regionResolver{region}
+}
+
+func (r *regionVolumeResolver) Brands() []*brandVolumeResolver {
+	return brandVolumes(r.r.Brands)
+}
+
+type brandVolumeResolver struct {
+	b reports.BrandVolume
+}
+
+func (r *brandVolumeResolver) Brand() string { return r.b.Brand }
+
+func (r *brandVolumeResolver) Quantity() *int32 {
+	if r.b.Quantity == nil {
+		return nil
+	}
+	q := int32(*r.b.Quantity)
+	return &q
+}
+
+func brandVolumes(brands []reports.BrandVolume) []*brandVolumeResolver {
+	result := make([]*brandVolumeResolver, len(brands))
+	for i, b := range brands {
+		result[i] = &brandVolumeResolver{b}
+	}
+	return result
+}
+
+type districtResolver struct {
+	d geo.District
+}
+
+func (r *districtResolver) Code() string   { return r.d.Code }
+func (r *districtResolver) Name() string   { return r.d.Name }
+func (r *districtResolver) NameEn() string { return r.d.NameEn }
+func (r *districtResolver) Short() string  { return r.d.Short }
+
+func (r *districtResolver) Regions(ctx context.Context) ([]*regionResolver, error) {
+	if err := fromContext(ctx).spend(costRegions); err != nil {
+		return nil, err
+	}
+	result := []*regionResolver{}
+	for _, region := range geo.Regions {
+		if region.District == r.d.Code {
+			result = append(result, &regionResolver{region})
+		}
+	}
+	return result, nil
+}
+
+type regionResolver struct {
+	r geo.Region
+}
+
+func (r *regionResolver) Code() string   { return r.r.Code }
+func (r *regionResolver) Name() string   { return r.r.Name }
+func (r *regionResolver) NameEn() string { return r.r.NameEn }
+
+func (r *regionResolver) Okato() *string {
+	if r.r.OKATO == "" {
+		return nil
+	}
+	return &r.r.OKATO
+}
+
+func (r *regionResolver) District() *districtResolver {
+	d, _ := geo.FindDistrict(r.r.District)
+	return &districtResolver{d}
+}
+
+func deref[T any](v *T) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	return *v
+}
@@ -3,54 +3,163 @@ package handlers
 import (
 	"net/http"
 
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/gql"
 	september2023 "truck-analytics-platform/internal/handlers/2023/september"
 	september2024 "truck-analytics-platform/internal/handlers/2024/september"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/metrics"
+	"truck-analytics-platform/internal/ratelimit"
+	"truck-analytics-platform/internal/scheduler"
+	"truck-analytics-platform/internal/tracing"
+	"truck-analytics-platform/internal/webhooks"
+
+	"truck-analytics-platform/internal/reports"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InitRouter() {
-
-	server := gin.Default()
-	server.Use(CORSMiddleware())
+// ReportRoute describes a segment report endpoint
+type ReportRoute struct {
+	Path    string          `json:"path"`
+	Year    int             `json:"year"`
+	Report  reports.Query   `json:"report"`
+	Handler gin.HandlerFunc `json:"-"`
+}
 
+var reportRoutes = []ReportRoute{
 	// 2023
 
 	// Tractors
-	server.Handle("GET", "/9m2023tractors4x2", september2023.NineMonth2023Tractors4x2)
-	server.Handle("GET", "/9m2023tractors6x4", september2023.NineMonth2023Tractors6x4)
+	{"/9m2023tractors4x2", 2023, september2023.Tractors4x2, september2023.NineMonth2023Tractors4x2},
+	{"/9m2023tractors6x4", 2023, september2023.Tractors6x4, september2023.NineMonth2023Tractors6x4},
 
 	// Dumpers
-	server.Handle("GET", "/9m2023dumpers6x4", september2023.NineMonth2023Dumpers6x4)
-	server.Handle("GET", "/9m2023dumpers8x4", september2023.NineMonth2023Dumpers8x4)
+	{"/9m2023dumpers6x4", 2023, september2023.Dumpers6x4, september2023.NineMonth2023Dumpers6x4},
+	{"/9m2023dumpers8x4", 2023, september2023.Dumpers8x4, september2023.NineMonth2023Dumpers8x4},
 
 	// -----------------------
 
 	// 2024
 
 	// Tractors
-	server.Handle("GET", "/9m2024tractors4x2", september2024.NineMonth2023Tractors4x2)
-	server.Handle("GET", "/9m2024tractors6x4", september2024.NineMonth2023Tractors6x4)
+	{"/9m2024tractors4x2", 2024, september2024.Tractors4x2, september2024.NineMonth2023Tractors4x2},
+	{"/9m2024tractors6x4", 2024, september2024.Tractors6x4, september2024.NineMonth2023Tractors6x4},
 
 	// Dumpers
-	server.Handle("GET", "/9m2024dumpers6x4", september2024.NineMonth2023Dumpers6x4)
-	server.Handle("GET", "/9m2024dumpers8x4", september2024.NineMonth2023Dumpers8x4)
+	{"/9m2024dumpers6x4", 2024, september2024.Dumpers6x4, september2024.NineMonth2023Dumpers6x4},
+	{"/9m2024dumpers8x4", 2024, september2024.Dumpers8x4, september2024.NineMonth2023Dumpers8x4},
+}
 
-	http.ListenAndServe(":8080", server)
+func InitRouter() {
+	http.ListenAndServe(":8080", NewRouter())
+}
 
+// chartHandler serves the charts of a report route
+func chartHandler(q reports.Query) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		reports.ServeChart(ctx, q)
+	}
 }
 
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+// NewRouter registers all routes
+func NewRouter() *gin.Engine {
+	server := gin.New()
+	cors := CORSConfigFromEnv()
+	server.Use(logging.Middleware(), gin.Recovery(), CORSMiddleware(cors), metrics.Middleware(), tracing.Middleware())
+
+	server.GET("/metrics", metrics.Handler())
+	server.GET("/healthz", Healthz)
+	server.GET("/readyz", Readyz)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204) // завершает запрос на этапе OPTIONS
-			return
-		}
+	// API documentation
+	server.GET("/openapi.json", OpenAPI)
+	server.GET("/docs", Docs)
 
-		c.Next()
+	guard := ratelimit.Default()
+	api := server.Group("/", guard.Client, auth.Middleware(auth.ConfigFromEnv()))
+
+	for _, r := range reportRoutes {
+		api.Handle("GET", r.Path, guard.Heavy, auth.RequireReport(r.Report.Segment, r.Year), guard.Queue, r.Handler)
+		api.Handle("GET", r.Path+"/chart/:kind", guard.Heavy, auth.RequireReport(r.Report.Segment, r.Year), guard.Queue, chartHandler(r.Report))
 	}
+
+	// Monthly market brief, every segment of a period
+	api.GET("/briefs/:year", guard.Heavy, guard.Queue, Brief)
+
+	// Custom aggregations over the whitelisted dimensions
+	api.POST("/pivot", guard.Heavy, guard.Queue, Pivot)
+
+	// Monthly outlook per brand
+	api.GET("/forecast/:segment", guard.Heavy, guard.Queue, Forecast)
+
+	// GraphQL over the segment reports and the region directory
+	api.POST("/graphql", guard.Heavy, guard.Queue, GraphQL(gql.New(gql.ConfigFromEnv())))
+	api.GET("/graphql/schema", guard.Light, GraphQLSchema)
+
+	// Saved report definitions and dashboards of the API key
+	api.GET("/definitions", guard.Light, ListDefinitions)
+	api.POST("/definitions", guard.Light, CreateDefinition)
+	api.GET("/definitions/:id", guard.Light, GetDefinition)
+	api.PUT("/definitions/:id", guard.Light, UpdateDefinition)
+	api.DELETE("/definitions/:id", guard.Light, DeleteDefinition)
+	api.GET("/definitions/:id/run", guard.Heavy, guard.Queue, RunDefinition)
+	api.GET("/dashboards", guard.Light, ListDashboards)
+	api.POST("/dashboards", guard.Light, CreateDashboard)
+	api.GET("/dashboards/:id", guard.Light, GetDashboard)
+	api.PUT("/dashboards/:id", guard.Light, UpdateDashboard)
+	api.DELETE("/dashboards/:id", guard.Light, DeleteDashboard)
+	api.GET("/dashboards/:id/run", guard.Heavy, guard.Queue, RunDashboard)
+
+	// Live notifications of data changes
+	api.GET("/events", guard.Light, Events)
+
+	// Metadata for front-end discovery
+	meta := api.Group("/meta", guard.Light)
+	meta.GET("/reports", Reports)
+	meta.GET("/datasets", Datasets)
+	meta.GET("/segments", Segments)
+	meta.GET("/districts", Districts)
+	meta.GET("/regions", Regions)
+	meta.GET("/brands", guard.Queue, Brands)
+	meta.GET("/body-types", guard.Queue, DistinctColumn("Body_type"))
+	meta.GET("/wheel-formulas", guard.Queue, DistinctColumn("Wheel_formula"))
+	meta.GET("/mass-segments", guard.Queue, MassSegments)
+
+	// API key management and data quality
+	admin := api.Group("/admin", guard.Light, auth.RequireAdmin())
+	admin.GET("/keys", ListKeys)
+	admin.POST("/keys", CreateKey)
+	admin.POST("/keys/:id/rotate", RotateKey)
+	admin.DELETE("/keys/:id", RevokeKey)
+	admin.GET("/quality", guard.Queue, QualityReport)
+
+	// Brand dictionary
+	admin.GET("/brands", ListBrands)
+	admin.POST("/brands", CreateBrand)
+	admin.GET("/brands/unmapped", guard.Queue, UnmappedBrands)
+	admin.DELETE("/brands/:name", guard.Queue, DeleteBrand)
+	admin.POST("/brands/:name/aliases", guard.Queue, AddBrandAlias)
+	admin.DELETE("/brands/:name/aliases/:alias", guard.Queue, DeleteBrandAlias)
+
+	// Scheduled report jobs
+	admin.GET("/jobs", ListJobs)
+	admin.POST("/jobs", CreateJob)
+	admin.GET("/jobs/:id", GetJob)
+	admin.PUT("/jobs/:id", UpdateJob)
+	admin.DELETE("/jobs/:id", DeleteJob)
+	admin.POST("/jobs/:id/run", RunJob(scheduler.New(scheduler.ConfigFromEnv())))
+	admin.GET("/jobs/:id/runs", JobRuns)
+
+	// Webhook subscriptions
+	admin.GET("/webhooks", ListWebhooks)
+	admin.POST("/webhooks", CreateWebhook)
+	admin.PUT("/webhooks/:id", UpdateWebhook)
+	admin.DELETE("/webhooks/:id", DeleteWebhook)
+	admin.POST("/webhooks/:id/test", TestWebhook(webhooks.ConfigFromEnv()))
+	admin.GET("/webhooks/:id/deliveries", WebhookDeliveries)
+
+	RegisterPreflight(server, cors)
+
+	return server
 }
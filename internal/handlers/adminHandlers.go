@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/quality"
+	"truck-analytics-platform/internal/saved"
+	"truck-analytics-platform/internal/scheduler"
+	"truck-analytics-platform/internal/segments"
+	"truck-analytics-platform/internal/tracing"
+	"truck-analytics-platform/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateKeyRequest struct {
+	Name        string           `json:"name" binding:"required"`
+	Permissions auth.Permissions `json:"permissions"`
+}
+
+// CreatedKey carries the secret, which is only shown once
+type CreatedKey struct {
+	auth.Key
+	Secret string `json:"secret"`
+}
+
+// QualityReport validates the loaded registration data
+func QualityReport(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		report, err := quality.Check(ctx.Request.Context(), conn)
+		return http.StatusOK, report, err
+	})
+}
+
+func ListKeys(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		keys, err := auth.List(ctx.Request.Context(), conn)
+		return http.StatusOK, keys, err
+	})
+}
+
+func CreateKey(ctx *gin.Context) {
+	var req CreateKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+	for _, name := range req.Permissions.Segments {
+		if _, ok := segments.Get(name); !ok {
+			fail(ctx, http.StatusBadRequest, "Unknown segment "+name)
+			return
+		}
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		if len(req.Permissions.Brands) > 0 {
+			canonical, err := brands.Canonical(ctx.Request.Context(), conn, req.Permissions.Brands)
+			if err != nil {
+				return 0, nil, err
+			}
+			req.Permissions.Brands = canonical
+		}
+
+		key, secret, err := auth.Create(ctx.Request.Context(), conn, req.Name, req.Permissions)
+		return http.StatusCreated, CreatedKey{Key: key, Secret: secret}, err
+	})
+}
+
+func RotateKey(ctx *gin.Context) {
+	id, ok := keyID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		key, secret, err := auth.Rotate(ctx.Request.Context(), conn, id)
+		return http.StatusOK, CreatedKey{Key: key, Secret: secret}, err
+	})
+}
+
+func RevokeKey(ctx *gin.Context) {
+	id, ok := keyID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		key, err := auth.Revoke(ctx.Request.Context(), conn, id)
+		return http.StatusOK, key, err
+	})
+}
+
+func keyID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid key id")
+		return 0, false
+	}
+	return id, true
+}
+
+// withConn runs fn on a fresh connection and writes its result. Not found
+// errors become 404, conflicts 409.
+func withConn(ctx *gin.Context, fn func(conn db.DB) (int, any, error)) {
+	// Name the queries after the route in traces
+	ctx.Request = ctx.Request.WithContext(tracing.WithOperation(ctx.Request.Context(), ctx.FullPath()))
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return
+	}
+
+	status, data, err := fn(conn)
+	if errors.Is(err, auth.ErrNotFound) || errors.Is(err, brands.ErrNotFound) || errors.Is(err, scheduler.ErrNotFound) ||
+		errors.Is(err, webhooks.ErrNotFound) || errors.Is(err, saved.ErrNotFound) {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, brands.ErrConflict) || errors.Is(err, saved.ErrConflict) {
+		fail(ctx, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+
+	ctx.JSON(status, MetaResponse{Data: data, Warning: ctx.GetString(warningKey)})
+}
+
+// warningKey holds the warning withConn adds to a successful response
+const warningKey = "warning"
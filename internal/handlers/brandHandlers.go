@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BrandRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type AliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+// Brands lists the canonical brands present in the data
+func Brands(ctx *gin.Context) {
+	withDatasets(ctx, func(conn db.DB, datasets []db.Dataset) (any, error) {
+		return brands.Present(ctx.Request.Context(), conn, datasets)
+	})
+}
+
+// ListBrands lists the brand dictionary
+func ListBrands(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		result, err := brands.List(ctx.Request.Context(), conn)
+		return http.StatusOK, result, err
+	})
+}
+
+func CreateBrand(ctx *gin.Context) {
+	var req BrandRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+
+	withBrandChange(ctx, http.StatusCreated, func(conn db.DB) (any, error) {
+		return brands.Create(ctx.Request.Context(), conn, req.Name)
+	})
+}
+
+func DeleteBrand(ctx *gin.Context) {
+	withBrandChange(ctx, http.StatusOK, func(conn db.DB) (any, error) {
+		return nil, brands.Delete(ctx.Request.Context(), conn, ctx.Param("name"))
+	})
+}
+
+func AddBrandAlias(ctx *gin.Context) {
+	var req AliasRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+
+	withBrandChange(ctx, http.StatusCreated, func(conn db.DB) (any, error) {
+		return brands.AddAlias(ctx.Request.Context(), conn, ctx.Param("name"), req.Alias)
+	})
+}
+
+func DeleteBrandAlias(ctx *gin.Context) {
+	withBrandChange(ctx, http.StatusOK, func(conn db.DB) (any, error) {
+		return brands.DeleteAlias(ctx.Request.Context(), conn, ctx.Param("name"), ctx.Param("alias"))
+	})
+}
+
+// UnmappedBrands lists raw brand values that match no canonical brand
+func UnmappedBrands(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		datasets, err := db.Datasets(ctx.Request.Context(), conn)
+		if err != nil {
+			return 0, nil, err
+		}
+		result, err := brands.UnmappedValues(ctx.Request.Context(), conn, datasets)
+		return http.StatusOK, result, err
+	})
+}
+
+// withBrandChange applies a dictionary change and refreshes the aggregates,
+// which hold canonical brand names, before responding. The change is
+// committed either way, a failed refresh only delays it in reports until the
+// next one and is returned as a warning.
+func withBrandChange(ctx *gin.Context, status int, fn func(conn db.DB) (any, error)) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		data, err := fn(conn)
+		if err != nil {
+			return 0, nil, err
+		}
+		if err := db.RefreshAggregates(ctx.Request.Context(), conn); err != nil {
+			slog.ErrorContext(ctx.Request.Context(), "Can't refresh aggregates after brand change", "error", err)
+			ctx.Set(warningKey, "Brand dictionary changed, but reports will show it only after the next aggregates refresh: "+err.Error())
+		}
+		return status, data, nil
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/briefs"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Brief renders the PDF market brief of a year, limited to the first
+// ?months= months. Keys only get the segments and brands they may query.
+func Brief(ctx *gin.Context) {
+	year, err := strconv.Atoi(ctx.Param("year"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid year")
+		return
+	}
+	months, err := strconv.Atoi(ctx.DefaultQuery("months", "0"))
+	if err != nil || months < 0 || months > 12 {
+		fail(ctx, http.StatusBadRequest, "months must be between 1 and 12")
+		return
+	}
+	logging.Annotate(ctx, slog.Group("params", slog.Int("year", year), slog.Int("months", months)))
+
+	var permissions auth.Permissions
+	if key, ok := auth.FromContext(ctx); ok {
+		permissions = key.Permissions
+	}
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return
+	}
+
+	queryCtx := tracing.WithOperation(ctx.Request.Context(), ctx.FullPath())
+	brief, err := briefs.Build(queryCtx, conn, year, months, permissions)
+	switch {
+	case errors.Is(err, briefs.ErrNoDataset):
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, briefs.ErrNoAccess):
+		fail(ctx, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+
+	_, span := tracing.Start(ctx.Request.Context(), "render pdf")
+	pdf, err := brief.PDF()
+	span.End()
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "Failed to render brief: "+err.Error())
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="brief-%d-%02d.pdf"`, brief.Year, brief.Months))
+	ctx.Data(http.StatusOK, "application/pdf", pdf)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"truck-analytics-platform/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultAllowedOrigins are the local front-end dev servers
+var DefaultAllowedOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
+
+type CORSConfig struct {
+	// AllowedOrigins may contain "*" to allow any origin, credentials are
+	// never allowed with it
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORSConfigFromEnv reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_HEADERS and
+// CORS_EXPOSED_HEADERS (comma separated), CORS_ALLOW_CREDENTIALS and
+// CORS_MAX_AGE (seconds). Only DefaultAllowedOrigins are allowed when no
+// origins are configured. Credentials are dropped when any origin is allowed.
+func CORSConfigFromEnv() CORSConfig {
+	cfg := CORSConfig{
+		AllowedOrigins: splitList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		AllowedHeaders: splitList(os.Getenv("CORS_ALLOWED_HEADERS")),
+		ExposedHeaders: splitList(os.Getenv("CORS_EXPOSED_HEADERS")),
+		MaxAge:         10 * time.Minute,
+	}
+	if len(cfg.AllowedOrigins) == 0 {
+		cfg.AllowedOrigins = DefaultAllowedOrigins
+	}
+	if len(cfg.ExposedHeaders) == 0 {
+		cfg.ExposedHeaders = []string{logging.RequestIDHeader, "Retry-After"}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", logging.RequestIDHeader}
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS")); err == nil {
+		cfg.AllowCredentials = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CORS_MAX_AGE")); err == nil {
+		cfg.MaxAge = time.Duration(v) * time.Second
+	}
+	if cfg.AllowCredentials && cfg.anyOrigin() {
+		slog.Warn("CORS credentials can't be allowed for any origin, list the origins in CORS_ALLOWED_ORIGINS")
+		cfg.AllowCredentials = false
+	}
+	return cfg
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for a request
+// origin, or "" when it isn't allowed
+func (cfg CORSConfig) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	if cfg.anyOrigin() {
+		return "*"
+	}
+	for _, o := range cfg.AllowedOrigins {
+		if strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func (cfg CORSConfig) anyOrigin() bool {
+	return slices.Contains(cfg.AllowedOrigins, "*")
+}
+
+func (cfg CORSConfig) setOrigin(c *gin.Context) bool {
+	h := c.Writer.Header()
+	h.Add("Vary", "Origin")
+
+	origin := cfg.allowOrigin(c.Request.Header.Get("Origin"))
+	if origin == "" {
+		return false
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	// Browsers reject credentials with "*" anyway, never send them with it
+	if cfg.AllowCredentials && origin != "*" {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// CORSMiddleware sets CORS headers on actual requests. Preflight requests
+// are answered by the handlers added with RegisterPreflight.
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodOptions && cfg.setOrigin(c) && len(cfg.ExposedHeaders) > 0 {
+			c.Writer.Header().Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+		}
+		c.Next()
+	}
+}
+
+// RegisterPreflight adds an OPTIONS handler for every registered path,
+// advertising only the methods that path supports. It must be called after
+// all routes are registered.
+func RegisterPreflight(server *gin.Engine, cfg CORSConfig) {
+	methods := make(map[string][]string)
+	var paths []string
+	for _, r := range server.Routes() {
+		if _, ok := methods[r.Path]; !ok {
+			paths = append(paths, r.Path)
+		}
+		methods[r.Path] = append(methods[r.Path], r.Method)
+	}
+
+	for _, path := range paths {
+		allowed := strings.Join(append(methods[path], http.MethodOptions), ", ")
+		server.OPTIONS(path, preflight(cfg, allowed))
+	}
+}
+
+func preflight(cfg CORSConfig, methods string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Allow", methods)
+
+		if cfg.setOrigin(c) {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			if cfg.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+		}
+
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/charts"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/forecast"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/gql"
+	"truck-analytics-platform/internal/openapi"
+	"truck-analytics-platform/internal/pivot"
+	"truck-analytics-platform/internal/quality"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/saved"
+	"truck-analytics-platform/internal/scheduler"
+	"truck-analytics-platform/internal/segments"
+	"truck-analytics-platform/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+var apiDocument = sync.OnceValue(buildAPIDocument)
+
+// OpenAPI serves the OpenAPI document of the report and metadata routes
+func OpenAPI(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, apiDocument())
+}
+
+// Docs serves a Swagger UI page for the OpenAPI document
+func Docs(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Truck analytics API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+	<script>
+		window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+	</script>
+</body>
+</html>
+`
+
+func buildAPIDocument() *openapi.Document {
+	doc := openapi.New("Truck analytics platform", "1.0.0")
+	doc.RequireAPIKey("ApiKey", "X-API-Key")
+
+	errorResponse := doc.Define("ErrorResponse", openapi.Schema{
+		"type": "object",
+		"properties": map[string]openapi.Schema{
+			"data":       {"type": "object", "nullable": true, "description": "null, when present"},
+			"error":      {"type": "string"},
+			"request_id": {"type": "string", "description": "Correlates the error with server logs"},
+		},
+		"required": []string{"error"},
+	})
+	failures := map[string]openapi.Response{
+		"401": openapi.JSONResponse("Missing or invalid API key", errorResponse),
+		"403": openapi.JSONResponse("API key has no access", errorResponse),
+		"429": openapi.JSONResponse("Rate limited, see Retry-After", errorResponse),
+		"500": openapi.JSONResponse("Query failed", errorResponse),
+		"503": openapi.JSONResponse("Database is unavailable", errorResponse),
+	}
+
+	// Report rows carry one nullable property per brand of the segment
+	rowType := reflect.TypeOf(reports.TruckAnalytics{})
+	for _, s := range segments.All {
+		properties := map[string]openapi.Schema{
+			"region_name": {"type": "string"},
+			"region_code": {"type": "string", "nullable": true, "description": "Code of the region, or of the district on district total rows, null when the name is not in the directory. Region codes follow ISO 3166-2:RU where the subject has one; RU-SEV, RU-CR, RU-DPR, RU-LPR, RU-ZAP and RU-KHE are not ISO codes. District codes are our own. See /meta/regions and /meta/districts"},
+		}
+		required := []string{"region_name", "region_code"}
+		for _, brand := range s.Brands {
+			properties[reports.BrandKey(brand)] = openapi.Schema{
+				"type":        "integer",
+				"nullable":    true,
+				"description": "Registrations of " + brand + ", null when there were none",
+			}
+			required = append(required, reports.BrandKey(brand))
+		}
+		properties["total"] = openapi.Schema{"type": "integer"}
+		required = append(required, "total")
+
+		row := doc.Define("TruckAnalytics_"+s.Name, openapi.Schema{
+			"type":        "object",
+			"description": "A region, or the federal district totals when region_name is the district",
+			"properties":  properties,
+			"required":    required,
+		})
+		doc.WithOverride(rowType, row, func() {
+			doc.Define("Response_"+s.Name, doc.Inline(reports.Response{}))
+		})
+	}
+	// Saved definitions and dashboards run any segment, brand columns vary
+	doc.Override(rowType, doc.Define("TruckAnalytics", openapi.Schema{
+		"type":        "object",
+		"description": "A region, or the federal district totals when region_name is the district, with one nullable property per brand of the segment",
+		"properties": map[string]openapi.Schema{
+			"region_name": {"type": "string"},
+			"region_code": {"type": "string", "nullable": true},
+			"total":       {"type": "integer"},
+		},
+		"required":             []string{"region_name", "region_code", "total"},
+		"additionalProperties": openapi.Schema{"type": "integer", "nullable": true},
+	}))
+
+	formatParam := openapi.Parameter{
+		Name:        "format",
+		In:          "query",
+		Description: "json groups regions by federal district, geojson returns a FeatureCollection of region boundaries keyed by region code",
+		Schema:      openapi.Schema{"type": "string", "enum": []string{reports.FormatJSON, reports.FormatGeoJSON}, "default": reports.FormatJSON},
+	}
+	var featureCollection openapi.Schema
+	geometry := openapi.Schema{"type": "object", "nullable": true, "description": "GeoJSON geometry"}
+	doc.WithOverride(reflect.TypeOf(json.RawMessage{}), geometry, func() {
+		featureCollection = doc.SchemaOf(reports.FeatureCollection{})
+	})
+
+	for _, r := range reportRoutes {
+		ok := openapi.JSONResponse("Regions grouped by federal district", openapi.Ref("Response_"+r.Report.Segment))
+		ok.Content["application/geo+json"] = openapi.MediaType{Schema: featureCollection}
+
+		op := openapi.Operation{
+			Summary:     reportSummary(r),
+			Tags:        []string{"reports"},
+			OperationID: r.Path[1:],
+			Parameters:  []openapi.Parameter{formatParam},
+			Responses: map[string]openapi.Response{
+				"200": ok,
+				"400": openapi.JSONResponse("Unknown format", errorResponse),
+				"501": openapi.JSONResponse("GeoJSON asked for but no region boundaries are configured", errorResponse),
+			},
+		}
+		for code, resp := range failures {
+			op.Responses[code] = resp
+		}
+		doc.Add(http.MethodGet, r.Path, op)
+
+		chart := openapi.Operation{
+			Summary:     reportSummary(r) + ", as a chart",
+			Tags:        []string{"charts"},
+			OperationID: r.Path[1:] + "_chart",
+			Parameters: []openapi.Parameter{
+				{Name: "kind", In: "path", Required: true, Schema: openapi.Schema{"type": "string", "enum": reports.ChartKinds},
+					Description: "districts: brand volumes per federal district, shares: brand shares per district, monthly: brand volumes per month"},
+				{Name: "format", In: "query", Schema: openapi.Schema{"type": "string", "enum": []string{charts.FormatSVG, charts.FormatPNG}, "default": charts.FormatSVG}},
+			},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "Chart image", Content: map[string]openapi.MediaType{
+					"image/svg+xml": {Schema: openapi.Schema{"type": "string"}},
+					"image/png":     {Schema: openapi.Schema{"type": "string", "format": "binary"}},
+				}},
+				"400": openapi.JSONResponse("Unknown chart or format", errorResponse),
+			},
+		}
+		for code, resp := range failures {
+			chart.Responses[code] = resp
+		}
+		doc.Add(http.MethodGet, r.Path+"/chart/:kind", chart)
+	}
+
+	brief := openapi.Operation{
+		Summary: "Market brief of a year as PDF: national summary, district tables, YoY changes and charts per segment",
+		Tags:    []string{"reports"},
+		Parameters: []openapi.Parameter{
+			{Name: "year", In: "path", Required: true, Schema: openapi.Schema{"type": "integer"}},
+			{Name: "months", In: "query", Description: "Limit to the first months of the year, all loaded months by default",
+				Schema: openapi.Schema{"type": "integer", "minimum": 1, "maximum": 12}},
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "PDF document", Content: map[string]openapi.MediaType{
+				"application/pdf": {Schema: openapi.Schema{"type": "string", "format": "binary"}},
+			}},
+			"400": openapi.JSONResponse("Invalid year or months", errorResponse),
+			"404": openapi.JSONResponse("No data for the year", errorResponse),
+		},
+	}
+	for code, resp := range failures {
+		brief.Responses[code] = resp
+	}
+	doc.Add(http.MethodGet, "/briefs/:year", brief)
+
+	measures := make([]string, 0, len(pivot.Measures))
+	for m := range pivot.Measures {
+		measures = append(measures, m)
+	}
+	slices.Sort(measures)
+	pivotOp := openapi.Operation{
+		Summary: "Aggregate a registration table by row and column dimensions. Dimensions are " +
+			strings.Join(pivot.Dimensions, ", ") + " and the mass segmentation columns of the table; measures are " +
+			strings.Join(measures, ", "),
+		Tags:        []string{"reports"},
+		RequestBody: openapi.JSONBody(doc.SchemaOf(pivot.Request{})),
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("OK", envelope(doc, pivot.Result{})),
+			"400": openapi.JSONResponse("Unknown dimension, filter or measure, or too many cells", errorResponse),
+		},
+	}
+	for code, resp := range failures {
+		pivotOp.Responses[code] = resp
+	}
+	doc.Add(http.MethodPost, "/pivot", pivotOp)
+
+	segmentNames := make([]string, len(segments.All))
+	for i, s := range segments.All {
+		segmentNames[i] = s.Name
+	}
+	forecastOp := openapi.Operation{
+		Summary: "Monthly forecast of a segment per brand and in total, with prediction intervals, " +
+			"next-quarter and full-year outlooks and backtest errors of the models tried",
+		Tags: []string{"reports"},
+		Parameters: []openapi.Parameter{
+			{Name: "segment", In: "path", Required: true, Schema: openapi.Schema{"type": "string", "enum": segmentNames}},
+			{Name: "brands", In: "query", Description: "Comma-separated brands, the segment brands by default",
+				Schema: openapi.Schema{"type": "string"}},
+			{Name: "model", In: "query", Description: "auto picks the model with the lowest backtest MAE",
+				Schema: openapi.Schema{"type": "string", "enum": forecast.Models, "default": forecast.ModelAuto}},
+			{Name: "horizon", In: "query", Description: "Months to forecast, at least to the end of the year",
+				Schema: openapi.Schema{"type": "integer", "minimum": 0, "maximum": forecast.MaxHorizon}},
+			{Name: "level", In: "query", Description: "Prediction interval level in percent",
+				Schema: openapi.Schema{"type": "integer", "enum": []int{80, 90, 95, 99}, "default": 95}},
+		},
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("OK", envelope(doc, forecast.Result{})),
+			"400": openapi.JSONResponse("Unknown segment or model, or invalid horizon or level", errorResponse),
+			"404": openapi.JSONResponse("No data for the segment", errorResponse),
+			"422": openapi.JSONResponse("Not enough history for the model", errorResponse),
+		},
+	}
+	for code, resp := range failures {
+		forecastOp.Responses[code] = resp
+	}
+	doc.Add(http.MethodGet, "/forecast/:segment", forecastOp)
+
+	graphQL := openapi.Operation{
+		Summary: "Run a GraphQL query over segments, periods, districts, regions and brand volumes. " +
+			"The schema is at /graphql/schema; queries are limited in depth and in the number of reports they run",
+		Tags:        []string{"reports"},
+		RequestBody: openapi.JSONBody(doc.SchemaOf(gql.Params{})),
+		Responses: map[string]openapi.Response{
+			"200": openapi.JSONResponse("GraphQL response, errors included", openapi.Schema{"type": "object",
+				"properties": map[string]openapi.Schema{
+					"data":   {"type": "object", "nullable": true},
+					"errors": {"type": "array", "items": openapi.Schema{"type": "object"}},
+				}}),
+			"400": openapi.JSONResponse("Invalid request", errorResponse),
+		},
+	}
+	for code, resp := range failures {
+		graphQL.Responses[code] = resp
+	}
+	doc.Add(http.MethodPost, "/graphql", graphQL)
+
+	graphQLSchema := openapi.Operation{
+		Summary: "GraphQL schema in SDL",
+		Tags:    []string{"reports"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Schema", Content: map[string]openapi.MediaType{"text/plain": {Schema: openapi.Schema{"type": "string"}}}},
+		},
+	}
+	for code, resp := range failures {
+		graphQLSchema.Responses[code] = resp
+	}
+	doc.Add(http.MethodGet, "/graphql/schema", graphQLSchema)
+
+	events := openapi.Operation{
+		Summary: "Server-Sent Events stream of data changes, resumable with Last-Event-ID",
+		Description: "Changes are detected by the report scheduler every SCHEDULER_INTERVAL_SECONDS. " +
+			"Nothing is streamed while the scheduler is disabled on every instance.",
+		Tags: []string{"meta"},
+		Parameters: []openapi.Parameter{
+			{Name: "types", In: "query", Description: "Comma-separated event types, all by default",
+				Schema: openapi.Schema{"type": "string", "enum": streamEvents}},
+			{Name: "Last-Event-ID", In: "header", Description: "ID of the last event received, to get the ones missed",
+				Schema: openapi.Schema{"type": "string", "format": "uuid"}},
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Event stream, data is a JSON object with id, type, created_at, data and the affected report paths in reports", Content: map[string]openapi.MediaType{
+				"text/event-stream": {Schema: openapi.Schema{"type": "string"}},
+			}},
+			"400": openapi.JSONResponse("Unknown event type", errorResponse),
+		},
+	}
+	for code, resp := range failures {
+		events.Responses[code] = resp
+	}
+	doc.Add(http.MethodGet, "/events", events)
+
+	meta := []struct {
+		path    string
+		summary string
+		data    any
+	}{
+		{"/meta/reports", "Report endpoints with their dataset and segment", []ReportRoute{}},
+		{"/meta/datasets", "Loaded registration periods", []db.Dataset{}},
+		{"/meta/segments", "Configured segments with their filters and brands", []segments.Segment{}},
+		{"/meta/districts", "Federal districts with their codes", []geo.District{}},
+		{"/meta/regions", "Federal subjects with their codes (ISO 3166-2:RU where assigned), OKATO codes and districts", []geo.Region{}},
+		{"/meta/brands", "Brands present in the data", []string{}},
+		{"/meta/body-types", "Body types present in the data", []string{}},
+		{"/meta/wheel-formulas", "Wheel formulas present in the data", []string{}},
+		{"/meta/mass-segments", "Values of each mass segmentation column", map[string][]string{}},
+	}
+	for _, m := range meta {
+		op := openapi.Operation{
+			Summary: m.summary,
+			Tags:    []string{"meta"},
+			Responses: map[string]openapi.Response{
+				"200": openapi.JSONResponse("OK", envelope(doc, m.data)),
+			},
+		}
+		for code, resp := range failures {
+			op.Responses[code] = resp
+		}
+		doc.Add(http.MethodGet, m.path, op)
+	}
+
+	idParam := []openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: openapi.Schema{"type": "integer"}}}
+	nameParam := []openapi.Parameter{{Name: "name", In: "path", Required: true, Schema: openapi.Schema{"type": "string"}}}
+	aliasParams := append(nameParam, openapi.Parameter{Name: "alias", In: "path", Required: true, Schema: openapi.Schema{"type": "string"}})
+	runParams := append(idParam[:1:1], openapi.Parameter{Name: "format", In: "query", Schema: openapi.Schema{"type": "string", "enum": saved.Formats}})
+	resources := []struct {
+		method  string
+		path    string
+		summary string
+		params  []openapi.Parameter
+		body    any
+		status  string
+		data    any
+	}{
+		{http.MethodGet, "/admin/keys", "List API keys", nil, nil, "200", []auth.Key{}},
+		{http.MethodPost, "/admin/keys", "Create an API key, the secret is returned once", nil, CreateKeyRequest{}, "201", CreatedKey{}},
+		{http.MethodPost, "/admin/keys/:id/rotate", "Replace the secret of an API key", idParam, nil, "200", CreatedKey{}},
+		{http.MethodDelete, "/admin/keys/:id", "Revoke an API key", idParam, nil, "200", auth.Key{}},
+		{http.MethodGet, "/admin/quality", "Validate the loaded registration data", nil, nil, "200", quality.Report{}},
+		{http.MethodGet, "/admin/brands", "List canonical brands with their aliases", nil, nil, "200", []brands.Brand{}},
+		{http.MethodPost, "/admin/brands", "Add a canonical brand", nil, BrandRequest{}, "201", brands.Brand{}},
+		{http.MethodGet, "/admin/brands/unmapped", "List raw brand values that match no canonical brand", nil, nil, "200", []brands.Unmapped{}},
+		{http.MethodDelete, "/admin/brands/:name", "Remove a canonical brand and its aliases", nameParam, nil, "200", nil},
+		{http.MethodPost, "/admin/brands/:name/aliases", "Map another spelling to a brand", nameParam, AliasRequest{}, "201", brands.Brand{}},
+		{http.MethodDelete, "/admin/brands/:name/aliases/:alias", "Remove an alias of a brand", aliasParams, nil, "200", brands.Brand{}},
+		{http.MethodGet, "/admin/jobs", "List scheduled report jobs", nil, nil, "200", []scheduler.Job{}},
+		{http.MethodPost, "/admin/jobs", "Create a report job run on a cron schedule and/or when new data is loaded", nil, scheduler.Job{}, "201", scheduler.Job{}},
+		{http.MethodGet, "/admin/jobs/:id", "Get a report job", idParam, nil, "200", scheduler.Job{}},
+		{http.MethodPut, "/admin/jobs/:id", "Replace a report job", idParam, scheduler.Job{}, "200", scheduler.Job{}},
+		{http.MethodDelete, "/admin/jobs/:id", "Delete a report job and its history", idParam, nil, "200", nil},
+		{http.MethodPost, "/admin/jobs/:id/run", "Run a report job now, in the background", idParam, nil, "202", scheduler.Run{}},
+		{http.MethodGet, "/admin/jobs/:id/runs", "Latest attempts of a report job", idParam, nil, "200", []scheduler.Run{}},
+		{http.MethodGet, "/admin/webhooks", "List webhook subscriptions", nil, nil, "200", []webhooks.Subscription{}},
+		{http.MethodPost, "/admin/webhooks", "Subscribe a URL to signed callbacks, the secret is returned once", nil, webhooks.Subscription{}, "201", webhooks.CreatedSubscription{}},
+		{http.MethodPut, "/admin/webhooks/:id", "Replace a webhook subscription", idParam, webhooks.Subscription{}, "200", webhooks.Subscription{}},
+		{http.MethodDelete, "/admin/webhooks/:id", "Delete a webhook subscription and its deliveries", idParam, nil, "200", nil},
+		{http.MethodPost, "/admin/webhooks/:id/test", "Send a ping event to a webhook now", idParam, nil, "200", webhooks.Delivery{}},
+		{http.MethodGet, "/admin/webhooks/:id/deliveries", "Latest deliveries of a webhook", idParam, nil, "200", []webhooks.Delivery{}},
+		{http.MethodGet, "/definitions", "List saved report definitions", nil, nil, "200", []saved.Definition{}},
+		{http.MethodPost, "/definitions", "Save a report definition", nil, saved.Definition{}, "201", saved.Definition{}},
+		{http.MethodGet, "/definitions/:id", "Get a saved report definition", idParam, nil, "200", saved.Definition{}},
+		{http.MethodPut, "/definitions/:id", "Replace a saved report definition", idParam, saved.Definition{}, "200", saved.Definition{}},
+		{http.MethodDelete, "/definitions/:id", "Delete a saved report definition, also from dashboards", idParam, nil, "200", nil},
+		{http.MethodGet, "/definitions/:id/run", "Run a saved report definition in its format, or the one in ?format=", runParams, nil, "200", saved.Result{}},
+		{http.MethodGet, "/dashboards", "List dashboards", nil, nil, "200", []saved.Dashboard{}},
+		{http.MethodPost, "/dashboards", "Create a dashboard of saved definitions", nil, saved.Dashboard{}, "201", saved.Dashboard{}},
+		{http.MethodGet, "/dashboards/:id", "Get a dashboard", idParam, nil, "200", saved.Dashboard{}},
+		{http.MethodPut, "/dashboards/:id", "Replace a dashboard", idParam, saved.Dashboard{}, "200", saved.Dashboard{}},
+		{http.MethodDelete, "/dashboards/:id", "Delete a dashboard", idParam, nil, "200", nil},
+		{http.MethodGet, "/dashboards/:id/run", "Run every definition of a dashboard", idParam, nil, "200", saved.DashboardResult{}},
+	}
+	for _, a := range resources {
+		op := openapi.Operation{
+			Summary:    a.summary,
+			Tags:       []string{strings.Split(a.path, "/")[1]},
+			Parameters: a.params,
+			Responses: map[string]openapi.Response{
+				a.status: openapi.JSONResponse("OK", envelope(doc, a.data)),
+				"404":    openapi.JSONResponse("Not found", errorResponse),
+			},
+		}
+		if a.body != nil {
+			op.RequestBody = openapi.JSONBody(doc.SchemaOf(a.body))
+			op.Responses["400"] = openapi.JSONResponse("Invalid request", errorResponse)
+		}
+		for code, resp := range failures {
+			op.Responses[code] = resp
+		}
+		doc.Add(a.method, a.path, op)
+	}
+
+	return doc
+}
+
+// envelope describes {"data": ..., "error": "..."} around data
+func envelope(doc *openapi.Document, data any) openapi.Schema {
+	return openapi.Schema{
+		"type": "object",
+		"properties": map[string]openapi.Schema{
+			"data":  doc.SchemaOf(data),
+			"error": {"type": "string"},
+		},
+		"required": []string{"data"},
+	}
+}
+
+func reportSummary(r ReportRoute) string {
+	if r.Report.Months > 0 {
+		return fmt.Sprintf("%s registrations, %d months of %d", r.Report.Segment, r.Report.Months, r.Year)
+	}
+	return fmt.Sprintf("%s registrations, %d", r.Report.Segment, r.Year)
+}
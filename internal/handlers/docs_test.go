@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/saved"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB answers queries with the rows of the first seed whose fragment is
+// in the SQL, and with no rows otherwise
+type fakeDB struct {
+	seeds []seed
+}
+
+type seed struct {
+	fragment string
+	rows     [][]any
+}
+
+func (f *fakeDB) rows(sql string) [][]any {
+	for _, s := range f.seeds {
+		if strings.Contains(sql, s.fragment) {
+			return s.rows
+		}
+	}
+	return nil
+}
+
+func (f *fakeDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.NewCommandTag("UPDATE 0"), nil
+}
+
+func (f *fakeDB) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	return &fakeRows{rows: f.rows(sql), i: -1}, nil
+}
+
+func (f *fakeDB) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	return &fakeRows{rows: f.rows(sql), i: -1}
+}
+
+func (f *fakeDB) Begin(context.Context) (pgx.Tx, error) {
+	return fakeTx{f}, nil
+}
+
+type fakeTx struct {
+	*fakeDB
+}
+
+func (fakeTx) Begin(context.Context) (pgx.Tx, error) { return nil, fmt.Errorf("nested transaction") }
+func (fakeTx) Commit(context.Context) error          { return nil }
+func (fakeTx) Rollback(context.Context) error        { return nil }
+func (fakeTx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, nil
+}
+func (fakeTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults { return nil }
+func (fakeTx) LargeObjects() pgx.LargeObjects                         { return pgx.LargeObjects{} }
+func (fakeTx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, nil
+}
+func (fakeTx) Conn() *pgx.Conn { return nil }
+
+type fakeRows struct {
+	rows [][]any
+	i    int
+	err  error
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return r.err }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.NewCommandTag("SELECT") }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+func (r *fakeRows) Values() ([]any, error)                       { return r.rows[r.i], nil }
+
+func (r *fakeRows) Next() bool {
+	r.i++
+	return r.err == nil && r.i < len(r.rows)
+}
+
+// Scan as a pgx.Row scans the first row
+func (r *fakeRows) Scan(dest ...any) error {
+	if r.i < 0 && !r.Next() {
+		return pgx.ErrNoRows
+	}
+	row := r.rows[r.i]
+	if len(row) != len(dest) {
+		r.err = fmt.Errorf("scanning %d columns into %d values", len(row), len(dest))
+		return r.err
+	}
+	for i, v := range row {
+		if err := assign(dest[i], v); err != nil {
+			r.err = fmt.Errorf("column %d: %w", i, err)
+			return r.err
+		}
+	}
+	return nil
+}
+
+func assign(dest, v any) error {
+	target := reflect.ValueOf(dest).Elem()
+	if v == nil {
+		target.SetZero()
+		return nil
+	}
+	value := reflect.ValueOf(v)
+	switch {
+	case value.Type().AssignableTo(target.Type()):
+		target.Set(value)
+	case target.Kind() == reflect.Pointer && value.Type().ConvertibleTo(target.Type().Elem()):
+		p := reflect.New(target.Type().Elem())
+		p.Elem().Set(value.Convert(target.Type().Elem()))
+		target.Set(p)
+	case value.Type().ConvertibleTo(target.Type()):
+		target.Set(value.Convert(target.Type()))
+	default:
+		return fmt.Errorf("can't scan %T into %s", v, target.Type())
+	}
+	return nil
+}
+
+// seeds hold a dataset for every report route, one region of each
+// district with the first brand of the route and a saved definition
+func seeds() []seed {
+	var datasets [][]any
+	for _, r := range reportRoutes {
+		if !slices.ContainsFunc(datasets, func(d []any) bool { return d[0] == r.Report.Dataset }) {
+			datasets = append(datasets, []any{r.Report.Dataset})
+		}
+	}
+	sort.Slice(datasets, func(i, j int) bool { return datasets[i][0].(string) < datasets[j][0].(string) })
+
+	var regions [][]any
+	for _, d := range geo.Districts {
+		for _, r := range geo.Regions {
+			if r.District == d.Code {
+				regions = append(regions,
+					[]any{d.Name, r.Name, r.Code, "FAW", int64(7)},
+					[]any{d.Name, d.Name, d.Code, "FAW", int64(7)})
+				break
+			}
+		}
+	}
+
+	return []seed{
+		{`truck\_analytics\_%`, datasets},
+		{"federal_totals", regions},
+		{"SELECT COALESCE(SUM(n - 1), 0)", [][]any{{int64(0)}}},
+		{"SELECT count(*) FROM ", [][]any{{int64(0)}}},
+		{"SELECT EXISTS (SELECT 1 FROM webhook_subscriptions", [][]any{{true}}},
+		{"updated_at FROM report_definitions", [][]any{
+			{int64(1), "weekly", "", "tractors4x2", 0, 0, []string{}, saved.ComparePreviousYear, reports.FormatJSON, time.Time{}, time.Time{}},
+		}},
+	}
+}
+
+// pathValues fills the parameters of documented paths
+var pathValues = map[string]string{
+	"{id}":      "1",
+	"{name}":    "FAW",
+	"{alias}":   "jiefang",
+	"{year}":    "2024",
+	"{segment}": "tractors4x2",
+	"{kind}":    "districts",
+}
+
+// streamed routes don't end, they are left out
+var streamed = []string{"/events"}
+
+// TestDocumentedRoutes calls every documented operation through the router
+// against a fake database and checks the status is documented and JSON
+// bodies match the response schema
+func TestDocumentedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_API_KEY", "contract-test")
+	t.Setenv("RATE_LIMIT_CLIENT", "1000:1000")
+	t.Setenv("RATE_LIMIT", "1000:1000")
+	t.Setenv("RATE_LIMIT_HEAVY", "1000:1000")
+
+	conn := &fakeDB{seeds: seeds()}
+	open := db.Open
+	db.Open = func(context.Context) (db.DB, error) { return conn, nil }
+	t.Cleanup(func() { db.Open = open })
+
+	router := NewRouter()
+
+	var document map[string]any
+	raw, err := json.Marshal(apiDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err)
+	}
+	v := validator{schemas: document["components"].(map[string]any)["schemas"].(map[string]any)}
+
+	paths := document["paths"].(map[string]any)
+	names := make([]string, 0, len(paths))
+	for path := range paths {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+
+	for _, path := range names {
+		if slices.Contains(streamed, path) {
+			continue
+		}
+		url := path
+		for param, value := range pathValues {
+			url = strings.ReplaceAll(url, param, value)
+		}
+		if strings.Contains(url, "{") {
+			t.Errorf("%s: no value for a path parameter", path)
+			continue
+		}
+
+		for method, op := range paths[path].(map[string]any) {
+			t.Run(strings.ToUpper(method)+" "+path, func(t *testing.T) {
+				var body io.Reader
+				if _, ok := op.(map[string]any)["requestBody"]; ok {
+					body = strings.NewReader("{}")
+				}
+				req := httptest.NewRequest(strings.ToUpper(method), url, body)
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set("X-API-Key", "contract-test")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				responses := op.(map[string]any)["responses"].(map[string]any)
+				status := fmt.Sprint(w.Code)
+				response, ok := responses[status].(map[string]any)
+				if !ok {
+					t.Fatalf("status %s is not documented, body %s", status, w.Body)
+				}
+				if w.Code == http.StatusInternalServerError {
+					t.Fatalf("query failed against the fake database: %s", w.Body)
+				}
+
+				content, _ := response["content"].(map[string]any)
+				mediaType, _, _ := strings.Cut(w.Header().Get("Content-Type"), ";")
+				media, ok := content[mediaType].(map[string]any)
+				if !ok {
+					t.Fatalf("content type %q is not documented for %s", mediaType, status)
+				}
+				if !strings.HasSuffix(mediaType, "json") {
+					return
+				}
+
+				var data any
+				if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				for _, problem := range v.validate(media["schema"].(map[string]any), data, "body") {
+					t.Error(problem)
+				}
+			})
+		}
+	}
+}
+
+// validator checks decoded JSON against the OpenAPI 3.0 schemas the
+// document generates. Objects may only have documented properties.
+type validator struct {
+	schemas map[string]any
+}
+
+func (v validator) validate(schema map[string]any, value any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := v.schemas[name].(map[string]any)
+		if !ok {
+			return []string{at + ": unknown schema " + ref}
+		}
+		return v.validate(target, value, at)
+	}
+	if value == nil {
+		if schema["nullable"] == true || len(schema) == 0 {
+			return nil
+		}
+		if all, ok := schema["allOf"].([]any); ok && schema["nullable"] == nil {
+			return v.validate(all[0].(map[string]any), nil, at)
+		}
+		return []string{at + ": null is not allowed"}
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		var problems []string
+		for _, s := range all {
+			problems = append(problems, v.validate(s.(map[string]any), value, at)...)
+		}
+		return problems
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return []string{fmt.Sprintf("%s: %v is not one of %v", at, value, enum)}
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an object", at, value)}
+		}
+		var problems []string
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing %s", at, name))
+			}
+		}
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		for name, field := range object {
+			if s, ok := properties[name].(map[string]any); ok {
+				problems = append(problems, v.validate(s, field, at+"."+name)...)
+			} else if additional != nil {
+				problems = append(problems, v.validate(additional, field, at+"."+name)...)
+			} else {
+				problems = append(problems, fmt.Sprintf("%s: undocumented property %s", at, name))
+			}
+		}
+		return problems
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T is not an array", at, value)}
+		}
+		var problems []string
+		for i, item := range array {
+			problems = append(problems, v.validate(schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s: %T is not a string", at, value)}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: %v is not an integer", at, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: %T is not a number", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %T is not a boolean", at, value)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/notify"
+	"truck-analytics-platform/internal/webhooks"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// Event types sent to stream clients
+var streamEvents = []string{webhooks.EventDatasetAdded, webhooks.EventReportChanged}
+
+var eventID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// StreamEvent is the data of a streamed event. Reports lists the report
+// routes whose numbers it affects, for clients to refresh.
+type StreamEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+	Reports   []string        `json:"reports"`
+}
+
+// Events streams data changes as Server-Sent Events: dataset.added when a
+// registration table is loaded and report.changed when a report's numbers
+// change. ?types= limits the event types. Clients reconnecting with
+// Last-Event-ID get the events they missed.
+//
+// Events are detected on the scheduler's ticks, so the stream stays silent
+// on deployments where every instance runs with SCHEDULER_DISABLED=true.
+func Events(ctx *gin.Context) {
+	types := streamEvents
+	if t := ctx.Query("types"); t != "" {
+		types = strings.Split(t, ",")
+		for _, name := range types {
+			if !slices.Contains(streamEvents, name) {
+				fail(ctx, http.StatusBadRequest, "Unknown event type "+name)
+				return
+			}
+		}
+	}
+	key, limited := auth.FromContext(ctx)
+
+	// Subscribe before catching up, so nothing is lost in between
+	events, stop := notify.Default.Subscribe()
+	defer stop()
+
+	missed, err := missedEvents(ctx, types, ctx.GetHeader("Last-Event-ID"))
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't replay events", "error", err)
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	// Events published while catching up may have been replayed already.
+	// The replayed IDs are only kept until the first keepalive, by then
+	// those events have been received.
+	replayed := map[string]bool{}
+	send := func(e notify.Event) {
+		if replayed[e.ID] || !slices.Contains(types, e.Type) {
+			return
+		}
+
+		var change struct {
+			Table   string `json:"table"`
+			Dataset string `json:"dataset"`
+			Segment string `json:"segment"`
+		}
+		json.Unmarshal(e.Data, &change)
+		dataset := change.Dataset
+		if e.Type == webhooks.EventDatasetAdded {
+			dataset = change.Table
+		}
+		if limited && !allowsChange(key.Permissions, dataset, change.Segment) {
+			return
+		}
+
+		ctx.Render(-1, sse.Event{Id: e.ID, Event: e.Type, Data: StreamEvent{
+			ID:        e.ID,
+			Type:      e.Type,
+			CreatedAt: e.CreatedAt,
+			Data:      e.Data,
+			Reports:   affectedReports(dataset, change.Segment),
+		}})
+	}
+
+	io.WriteString(ctx.Writer, "retry: 5000\n\n")
+	for _, e := range missed {
+		send(e)
+		replayed[e.ID] = true
+	}
+	ctx.Writer.Flush()
+
+	// Comments keep proxies from closing idle streams
+	keepalive := time.NewTicker(25 * time.Second)
+	defer keepalive.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case e := <-events:
+			send(e)
+		case <-keepalive.C:
+			replayed = nil
+			io.WriteString(w, ": keepalive\n\n")
+		}
+		return true
+	})
+}
+
+// missedEvents returns the events after the one a client saw last
+func missedEvents(ctx *gin.Context, types []string, lastID string) ([]notify.Event, error) {
+	if !eventID.MatchString(lastID) {
+		return nil, nil
+	}
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx.Request.Context(), `
+		SELECT id, type, created_at, data FROM webhook_events
+		WHERE seq > (SELECT seq FROM webhook_events WHERE id = $1::uuid) AND type = ANY ($2)
+		ORDER BY seq
+		LIMIT 1000
+	`, lastID, types)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []notify.Event
+	for rows.Next() {
+		var e notify.Event
+		if err := rows.Scan(&e.ID, &e.Type, &e.CreatedAt, &e.Data); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// allowsChange reports whether a key may see a change of a dataset, or of
+// one segment of it
+func allowsChange(p auth.Permissions, dataset, segment string) bool {
+	d, ok := db.ParseDataset(dataset)
+	if !ok {
+		return false
+	}
+	if segment == "" {
+		return len(p.Years) == 0 || slices.Contains(p.Years, d.Year)
+	}
+	return p.AllowsReport(segment, d.Year)
+}
+
+// affectedReports lists the report routes reading a dataset, or one segment
+// of it
+func affectedReports(dataset, segment string) []string {
+	paths := []string{}
+	for _, r := range reportRoutes {
+		if r.Report.Dataset == dataset && (segment == "" || r.Report.Segment == segment) {
+			paths = append(paths, r.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/forecast"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Forecast projects the monthly registrations of a segment per brand with
+// prediction intervals, next-quarter and full-year outlooks and backtest
+// errors of the models. Keys only get the brands and years they may query.
+func Forecast(ctx *gin.Context) {
+	req := forecast.Request{
+		Segment: ctx.Param("segment"),
+		Model:   ctx.Query("model"),
+	}
+	var err error
+	if req.Horizon, err = strconv.Atoi(ctx.DefaultQuery("horizon", "0")); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid horizon")
+		return
+	}
+	if req.Level, err = strconv.Atoi(ctx.DefaultQuery("level", "95")); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid level")
+		return
+	}
+	if raw := ctx.Query("brands"); raw != "" {
+		req.Brands = strings.Split(raw, ",")
+	}
+	logging.Annotate(ctx, slog.Group("params",
+		slog.String("segment", req.Segment),
+		slog.String("model", req.Model),
+		slog.Int("horizon", req.Horizon),
+		slog.Int("level", req.Level),
+	))
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return
+	}
+
+	queryCtx := tracing.WithOperation(ctx.Request.Context(), ctx.FullPath())
+	result, err := forecast.Run(queryCtx, conn, req, permissions(ctx))
+	switch {
+	case errors.Is(err, forecast.ErrInvalid):
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, forecast.ErrNoAccess):
+		fail(ctx, http.StatusForbidden, err.Error())
+		return
+	case errors.Is(err, forecast.ErrNoData):
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, forecast.ErrShortHistory):
+		fail(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+
+	tracing.JSON(ctx, http.StatusOK, MetaResponse{Data: result})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/gql"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQL executes a query against the segment analytics schema. Like any
+// GraphQL server it responds 200 with errors in the body once the request
+// could be read.
+func GraphQL(s *gql.Server) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var params gql.Params
+		if err := ctx.ShouldBindJSON(&params); err != nil {
+			fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+			return
+		}
+		logging.Annotate(ctx, slog.Group("params", slog.String("operation", params.OperationName)))
+
+		conn, err := db.Open(ctx.Request.Context())
+		if err != nil {
+			slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+			fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+			return
+		}
+
+		queryCtx := tracing.WithOperation(ctx.Request.Context(), "graphql "+params.OperationName)
+		resp := s.Exec(queryCtx, conn, permissions(ctx), params)
+		if len(resp.Errors) > 0 {
+			logging.Annotate(ctx, slog.Int("graphql_errors", len(resp.Errors)))
+		}
+		tracing.JSON(ctx, http.StatusOK, resp)
+	}
+}
+
+// GraphQLSchema serves the schema in SDL
+func GraphQLSchema(ctx *gin.Context) {
+	ctx.String(http.StatusOK, gql.Schema())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthCheck struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Detail any    `json:"detail,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// Healthz reports that the process is up. It doesn't touch dependencies so
+// a database outage doesn't get the container restarted.
+func Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// Readyz checks that reports can be served: the database answers, the schema
+// is migrated and the aggregates cover all loaded data
+func Readyz(ctx *gin.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	checks := map[string]HealthCheck{}
+	ready := func() {
+		status, code := "ok", http.StatusOK
+		for _, c := range checks {
+			if !c.OK {
+				status, code = "unavailable", http.StatusServiceUnavailable
+			}
+		}
+		ctx.JSON(code, HealthResponse{Status: status, Checks: checks})
+	}
+
+	conn, err := db.Open(checkCtx)
+	if err == nil {
+		err = db.Ping(checkCtx, conn)
+	}
+	if err != nil {
+		checks["database"] = HealthCheck{Error: err.Error()}
+		ready()
+		return
+	}
+	checks["database"] = HealthCheck{OK: true}
+
+	current, latest, err := db.MigrationVersion(checkCtx, conn)
+	checks["migrations"] = HealthCheck{
+		OK:     err == nil && current == latest,
+		Error:  errorText(err),
+		Detail: gin.H{"current": current, "latest": latest},
+	}
+
+	datasets, err := db.Datasets(checkCtx, conn)
+	if err != nil {
+		checks["data"] = HealthCheck{Error: err.Error()}
+		ready()
+		return
+	}
+	upToDate, err := db.AggregatesCurrent(checkCtx, conn, datasets)
+	detail := gin.H{"datasets": len(datasets), "aggregates_current": upToDate}
+	if len(datasets) > 0 {
+		last := datasets[len(datasets)-1]
+		detail["latest_period"] = fmt.Sprintf("%d-%02d", last.Year, last.ToMonth)
+	}
+	checks["data"] = HealthCheck{
+		OK:     err == nil && len(datasets) > 0 && upToDate,
+		Error:  errorText(err),
+		Detail: detail,
+	}
+
+	ready()
+}
+
+func errorText(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListJobs(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		jobs, err := scheduler.List(ctx.Request.Context(), conn)
+		return http.StatusOK, jobs, err
+	})
+}
+
+func GetJob(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		job, err := scheduler.Get(ctx.Request.Context(), conn, id)
+		return http.StatusOK, job, err
+	})
+}
+
+func CreateJob(ctx *gin.Context) {
+	job, ok := bindJob(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		job, err := scheduler.Create(ctx.Request.Context(), conn, job)
+		return http.StatusCreated, job, err
+	})
+}
+
+func UpdateJob(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+	job, ok := bindJob(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		job, err := scheduler.Update(ctx.Request.Context(), conn, id, job)
+		return http.StatusOK, job, err
+	})
+}
+
+func DeleteJob(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		return http.StatusOK, nil, scheduler.Delete(ctx.Request.Context(), conn, id)
+	})
+}
+
+// RunJob starts a job now. It responds with the run, which finishes in the
+// background; poll the job runs for the outcome.
+func RunJob(s *scheduler.Scheduler) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, ok := jobID(ctx)
+		if !ok {
+			return
+		}
+
+		withConn(ctx, func(conn db.DB) (int, any, error) {
+			run, err := s.Trigger(ctx.Request.Context(), conn, id)
+			return http.StatusAccepted, run, err
+		})
+	}
+}
+
+// JobRuns lists the latest attempts of a job, ?limit= of them (50 by default)
+func JobRuns(ctx *gin.Context) {
+	id, ok := jobID(ctx)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 1000 {
+		fail(ctx, http.StatusBadRequest, "limit must be between 1 and 1000")
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		runs, err := scheduler.Runs(ctx.Request.Context(), conn, id, limit)
+		return http.StatusOK, runs, err
+	})
+}
+
+func bindJob(ctx *gin.Context) (scheduler.Job, bool) {
+	job := scheduler.Job{Enabled: true}
+	if err := ctx.ShouldBindJSON(&job); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return job, false
+	}
+	if err := job.Validate(); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid job: "+err.Error())
+		return job, false
+	}
+	return job, true
+}
+
+func jobID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid job id")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/segments"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetaResponse struct {
+	Data  any    `json:"data"`
+	Error string `json:"error,omitempty"`
+	// Warning is set when the request succeeded but a follow-up step didn't
+	Warning   string `json:"warning,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// fail writes an error envelope carrying the request ID and records the
+// error for the access log
+func fail(ctx *gin.Context, status int, message string) {
+	ctx.Error(errors.New(message))
+	ctx.JSON(status, MetaResponse{Error: message, RequestID: logging.RequestID(ctx)})
+}
+
+// Reports lists the report endpoints with the dataset and segment behind each
+func Reports(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, MetaResponse{Data: reportRoutes})
+}
+
+// Segments lists the configured segments with their filters and brands
+func Segments(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, MetaResponse{Data: segments.All})
+}
+
+// Districts lists the federal districts of the geo directory
+func Districts(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, MetaResponse{Data: geo.Districts})
+}
+
+// Regions lists the federal subjects with their codes and districts
+func Regions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, MetaResponse{Data: geo.Regions})
+}
+
+// Datasets lists the loaded registration periods
+func Datasets(ctx *gin.Context) {
+	withDatasets(ctx, func(conn db.DB, datasets []db.Dataset) (any, error) {
+		return datasets, nil
+	})
+}
+
+// DistinctColumn lists the values of a registration column present in the data
+func DistinctColumn(column string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		withDatasets(ctx, func(conn db.DB, datasets []db.Dataset) (any, error) {
+			return db.DistinctValues(ctx.Request.Context(), conn, column, datasets)
+		})
+	}
+}
+
+// MassSegments lists the values of every mass segmentation column
+func MassSegments(ctx *gin.Context) {
+	withDatasets(ctx, func(conn db.DB, datasets []db.Dataset) (any, error) {
+		columns, err := db.MassSegmentColumns(ctx.Request.Context(), conn, datasets)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[string][]string, len(columns))
+		for _, column := range columns {
+			values, err := db.DistinctValues(ctx.Request.Context(), conn, column, datasets)
+			if err != nil {
+				return nil, err
+			}
+			result[column] = values
+		}
+		return result, nil
+	})
+}
+
+func withDatasets(ctx *gin.Context, fn func(conn db.DB, datasets []db.Dataset) (any, error)) {
+	// Name the queries after the route in traces
+	ctx.Request = ctx.Request.WithContext(tracing.WithOperation(ctx.Request.Context(), ctx.FullPath()))
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return
+	}
+
+	datasets, err := db.Datasets(ctx.Request.Context(), conn)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "Failed to list datasets: "+err.Error())
+		return
+	}
+
+	data, err := fn(conn, datasets)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MetaResponse{Data: data})
+}
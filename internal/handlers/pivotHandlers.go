@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/pivot"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pivot aggregates a registration table by the dimensions in the request
+// body. Keys limited to some segments, years or brands only see those.
+func Pivot(ctx *gin.Context) {
+	var req pivot.Request
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+	logging.Annotate(ctx, slog.Group("params",
+		slog.String("dataset", req.Dataset),
+		slog.String("segment", req.Segment),
+		slog.String("rows", strings.Join(req.Rows, ",")),
+		slog.String("column", req.Column),
+		slog.String("measure", req.Measure),
+	))
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return
+	}
+
+	queryCtx := tracing.WithOperation(ctx.Request.Context(), ctx.FullPath())
+	result, err := pivot.Run(queryCtx, conn, req, permissions(ctx))
+	switch {
+	case errors.Is(err, pivot.ErrInvalid):
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, pivot.ErrNoAccess):
+		fail(ctx, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+
+	logging.Annotate(ctx, slog.Int("rows", len(result.Data)))
+	tracing.JSON(ctx, http.StatusOK, MetaResponse{Data: result})
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/saved"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListDefinitions(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		definitions, err := saved.ListDefinitions(ctx.Request.Context(), conn, owner(ctx))
+		return http.StatusOK, definitions, err
+	})
+}
+
+func GetDefinition(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		definition, err := saved.GetDefinition(ctx.Request.Context(), conn, owner(ctx), id)
+		return http.StatusOK, definition, err
+	})
+}
+
+func CreateDefinition(ctx *gin.Context) {
+	definition, ok := bindDefinition(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		var err error
+		if definition.Brands, err = canonicalBrands(ctx, conn, definition.Brands); err != nil {
+			return 0, nil, err
+		}
+		definition, err := saved.CreateDefinition(ctx.Request.Context(), conn, owner(ctx), definition)
+		return http.StatusCreated, definition, err
+	})
+}
+
+func UpdateDefinition(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+	definition, ok := bindDefinition(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		var err error
+		if definition.Brands, err = canonicalBrands(ctx, conn, definition.Brands); err != nil {
+			return 0, nil, err
+		}
+		definition, err := saved.UpdateDefinition(ctx.Request.Context(), conn, owner(ctx), id, definition)
+		return http.StatusOK, definition, err
+	})
+}
+
+func DeleteDefinition(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		return http.StatusOK, nil, saved.DeleteDefinition(ctx.Request.Context(), conn, owner(ctx), id)
+	})
+}
+
+// RunDefinition executes a saved definition in its format, or the one asked
+// for with ?format=
+func RunDefinition(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+	format := ctx.Query("format")
+	if format != "" && !slices.Contains(saved.Formats, format) {
+		fail(ctx, http.StatusBadRequest, "Unknown format "+format)
+		return
+	}
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return
+	}
+
+	queryCtx := tracing.WithOperation(ctx.Request.Context(), ctx.FullPath())
+	definition, err := saved.GetDefinition(queryCtx, conn, owner(ctx), id)
+	if err != nil {
+		failSaved(ctx, err)
+		return
+	}
+	if format == "" {
+		format = definition.Format
+	}
+	logging.Annotate(ctx, slog.Group("params", slog.Int64("definition", id), slog.String("format", format)))
+
+	result, err := saved.Run(queryCtx, conn, definition, permissions(ctx))
+	if err != nil {
+		failSaved(ctx, err)
+		return
+	}
+
+	switch format {
+	case reports.FormatGeoJSON:
+		fc, err := reports.GeoJSON(result.Query, result.Data)
+		if err != nil {
+			fail(ctx, http.StatusNotImplemented, err.Error())
+			return
+		}
+		ctx.Header("Content-Type", "application/geo+json; charset=utf-8")
+		tracing.JSON(ctx, http.StatusOK, fc)
+	case exports.FormatCSV, exports.FormatXLSX:
+		contentType, data, err := exports.Encode(format, result.Tables())
+		if err != nil {
+			fail(ctx, http.StatusInternalServerError, "Failed to export report: "+err.Error())
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%d.%s"`, id, format))
+		ctx.Data(http.StatusOK, contentType, data)
+	default:
+		tracing.JSON(ctx, http.StatusOK, MetaResponse{Data: result})
+	}
+}
+
+func ListDashboards(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		dashboards, err := saved.ListDashboards(ctx.Request.Context(), conn, owner(ctx))
+		return http.StatusOK, dashboards, err
+	})
+}
+
+func GetDashboard(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		dashboard, err := saved.GetDashboard(ctx.Request.Context(), conn, owner(ctx), id)
+		return http.StatusOK, dashboard, err
+	})
+}
+
+func CreateDashboard(ctx *gin.Context) {
+	var dashboard saved.Dashboard
+	if err := ctx.ShouldBindJSON(&dashboard); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		dashboard, err := saved.CreateDashboard(ctx.Request.Context(), conn, owner(ctx), dashboard)
+		return http.StatusCreated, dashboard, err
+	})
+}
+
+func UpdateDashboard(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+	var dashboard saved.Dashboard
+	if err := ctx.ShouldBindJSON(&dashboard); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		dashboard, err := saved.UpdateDashboard(ctx.Request.Context(), conn, owner(ctx), id, dashboard)
+		return http.StatusOK, dashboard, err
+	})
+}
+
+func DeleteDashboard(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		return http.StatusOK, nil, saved.DeleteDashboard(ctx.Request.Context(), conn, owner(ctx), id)
+	})
+}
+
+// RunDashboard executes every definition of a dashboard as JSON
+func RunDashboard(ctx *gin.Context) {
+	id, ok := savedID(ctx)
+	if !ok {
+		return
+	}
+	logging.Annotate(ctx, slog.Group("params", slog.Int64("dashboard", id)))
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		result, err := saved.RunDashboard(ctx.Request.Context(), conn, owner(ctx), id, permissions(ctx))
+		return http.StatusOK, result, err
+	})
+}
+
+// owner is the API key saved items are created for and listed by. Admin
+// keys and requests without authentication see all of them.
+func owner(ctx *gin.Context) saved.Owner {
+	key, ok := auth.FromContext(ctx)
+	if !ok {
+		return saved.Owner{All: true}
+	}
+	o := saved.Owner{All: key.Permissions.Admin}
+	if key.ID != 0 {
+		o.Key = &key.ID
+	}
+	return o
+}
+
+func permissions(ctx *gin.Context) auth.Permissions {
+	key, _ := auth.FromContext(ctx)
+	return key.Permissions
+}
+
+func canonicalBrands(ctx *gin.Context, conn db.DB, raw []string) ([]string, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	return brands.Canonical(ctx.Request.Context(), conn, raw)
+}
+
+func bindDefinition(ctx *gin.Context) (saved.Definition, bool) {
+	definition := saved.Definition{Format: reports.FormatJSON}
+	if err := ctx.ShouldBindJSON(&definition); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return definition, false
+	}
+	if err := definition.Validate(); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid definition: "+err.Error())
+		return definition, false
+	}
+	return definition, true
+}
+
+// failSaved writes the error of loading or running a saved definition
+func failSaved(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, saved.ErrNotFound) || errors.Is(err, saved.ErrNoDataset):
+		fail(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, saved.ErrNoAccess):
+		fail(ctx, http.StatusForbidden, err.Error())
+	default:
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+	}
+}
+
+func savedID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid id")
+		return 0, false
+	}
+	return id, true
+}
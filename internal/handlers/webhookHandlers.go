@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListWebhooks(ctx *gin.Context) {
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		subscriptions, err := webhooks.List(ctx.Request.Context(), conn)
+		return http.StatusOK, subscriptions, err
+	})
+}
+
+// CreateWebhook responds with the signing secret, which isn't shown again
+func CreateWebhook(ctx *gin.Context) {
+	subscription, ok := bindWebhook(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		created, err := webhooks.Create(ctx.Request.Context(), conn, subscription)
+		return http.StatusCreated, created, err
+	})
+}
+
+func UpdateWebhook(ctx *gin.Context) {
+	id, ok := webhookID(ctx)
+	if !ok {
+		return
+	}
+	subscription, ok := bindWebhook(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		updated, err := webhooks.Update(ctx.Request.Context(), conn, id, subscription)
+		return http.StatusOK, updated, err
+	})
+}
+
+func DeleteWebhook(ctx *gin.Context) {
+	id, ok := webhookID(ctx)
+	if !ok {
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		return http.StatusOK, nil, webhooks.Delete(ctx.Request.Context(), conn, id)
+	})
+}
+
+// TestWebhook sends a ping event and responds with the delivery once the
+// endpoint answered. A failed ping is not retried.
+func TestWebhook(cfg webhooks.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, ok := webhookID(ctx)
+		if !ok {
+			return
+		}
+
+		withConn(ctx, func(conn db.DB) (int, any, error) {
+			delivery, err := webhooks.Test(ctx.Request.Context(), conn, cfg, id)
+			return http.StatusOK, delivery, err
+		})
+	}
+}
+
+// WebhookDeliveries lists the latest deliveries of a subscription, ?limit=
+// of them (50 by default)
+func WebhookDeliveries(ctx *gin.Context) {
+	id, ok := webhookID(ctx)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 1000 {
+		fail(ctx, http.StatusBadRequest, "limit must be between 1 and 1000")
+		return
+	}
+
+	withConn(ctx, func(conn db.DB) (int, any, error) {
+		deliveries, err := webhooks.Deliveries(ctx.Request.Context(), conn, id, limit)
+		return http.StatusOK, deliveries, err
+	})
+}
+
+func bindWebhook(ctx *gin.Context) (webhooks.Subscription, bool) {
+	subscription := webhooks.Subscription{Enabled: true}
+	if err := ctx.ShouldBindJSON(&subscription); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid request: "+err.Error())
+		return subscription, false
+	}
+	if err := subscription.Validate(); err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid webhook: "+err.Error())
+		return subscription, false
+	}
+	return subscription, true
+}
+
+func webhookID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "Invalid webhook id")
+		return 0, false
+	}
+	return id, true
+}
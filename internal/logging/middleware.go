@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDContextKey = "requestID"
+	attrsContextKey     = "logAttrs"
+)
+
+// Middleware assigns every request an ID, taken from X-Request-ID when the
+// caller sent a usable one, and writes one JSON access log line per request
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		id := UsableRequestID(ctx.GetHeader(RequestIDHeader))
+		ctx.Set(requestIDContextKey, id)
+		ctx.Header(RequestIDHeader, id)
+		ctx.Request = ctx.Request.WithContext(WithRequestID(ctx.Request.Context(), id))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		attrs := []any{
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", ctx.FullPath()),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", ctx.ClientIP()),
+			slog.Int("bytes", ctx.Writer.Size()),
+		}
+		if query := ctx.Request.URL.RawQuery; query != "" {
+			attrs = append(attrs, slog.String("query", query))
+		}
+		if extra, ok := ctx.Get(attrsContextKey); ok {
+			for _, a := range extra.([]slog.Attr) {
+				attrs = append(attrs, a)
+			}
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", strings.Join(ctx.Errors.Errors(), "; ")))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(ctx.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Annotate adds attributes to the access log line of the request, e.g. the
+// report name and row count
+func Annotate(ctx *gin.Context, attrs ...slog.Attr) {
+	existing, _ := ctx.Get(attrsContextKey)
+	list, _ := existing.([]slog.Attr)
+	ctx.Set(attrsContextKey, append(list, attrs...))
+}
+
+// RequestID returns the ID of the request, to be included in error responses
+func RequestID(ctx *gin.Context) string {
+	return ctx.GetString(requestIDContextKey)
+}
+
+// UsableRequestID returns id when it can be used as a request ID, otherwise
+// a new one
+func UsableRequestID(id string) string {
+	if validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
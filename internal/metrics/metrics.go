@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "report_query_duration_seconds",
+		Help:    "Database time spent per report.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"report", "result"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limited_requests_total",
+		Help: "Requests rejected with 429 by route and reason (client, rate, concurrency).",
+	}, []string{"route", "reason"})
+
+	aggregateRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "aggregate_refreshes_total",
+		Help: "Refreshes of the report aggregates after data loads, by mode (rebuild, refresh) and result.",
+	}, []string{"mode", "result"})
+
+	aggregateRefreshDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "aggregate_refresh_duration_seconds",
+		Help:    "Time taken to refresh the report aggregates.",
+		Buckets: []float64{.1, .5, 1, 5, 15, 30, 60, 120, 300},
+	})
+
+	aggregateRefreshed = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "aggregate_last_refresh_timestamp_seconds",
+		Help: "Unix time of the last successful aggregates refresh.",
+	})
+
+	datasets = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "datasets_loaded",
+		Help: "Number of truck_analytics_* tables found at the last refresh.",
+	})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records count and latency of every request, labelled by the
+// route pattern so that path parameters don't explode cardinality
+func Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := ctx.Request.Method
+
+		httpRequests.WithLabelValues(route, method, strconv.Itoa(ctx.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveQuery records the database time of a report
+func ObserveQuery(report string, d time.Duration, err error) {
+	queryDuration.WithLabelValues(report, result(err)).Observe(d.Seconds())
+}
+
+// ObserveRateLimited records a request rejected by rate limiting
+func ObserveRateLimited(route, reason string) {
+	rateLimited.WithLabelValues(route, reason).Inc()
+}
+
+// ObserveRefresh records an aggregates refresh
+func ObserveRefresh(mode string, tables int, d time.Duration, err error) {
+	aggregateRefreshes.WithLabelValues(mode, result(err)).Inc()
+	aggregateRefreshDuration.Observe(d.Seconds())
+	if err == nil {
+		aggregateRefreshed.SetToCurrentTime()
+		datasets.Set(float64(tables))
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// RegisterPool exports connection pool statistics and the buffer cache
+// hits of the database
+func RegisterPool(pool *pgxpool.Pool) {
+	prometheus.MustRegister(poolCollector{pool}, cacheCollector{pool})
+}
+
+var (
+	poolAcquired      = prometheus.NewDesc("db_pool_acquired_connections", "Connections currently in use.", nil, nil)
+	poolIdle          = prometheus.NewDesc("db_pool_idle_connections", "Idle connections.", nil, nil)
+	poolTotal         = prometheus.NewDesc("db_pool_total_connections", "Open connections.", nil, nil)
+	poolMax           = prometheus.NewDesc("db_pool_max_connections", "Maximum pool size.", nil, nil)
+	poolAcquires      = prometheus.NewDesc("db_pool_acquires_total", "Successful connection acquires.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", nil, nil)
+	poolAcquireTime   = prometheus.NewDesc("db_pool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil)
+)
+
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquired
+	ch <- poolIdle
+	ch <- poolTotal
+	ch <- poolMax
+	ch <- poolAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolAcquireTime
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireTime, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+var (
+	cacheHits  = prometheus.NewDesc("db_cache_blocks_hit_total", "Blocks read from the Postgres buffer cache.", nil, nil)
+	cacheReads = prometheus.NewDesc("db_cache_blocks_read_total", "Blocks read from disk, the hit rate is hit / (hit + read).", nil, nil)
+)
+
+// cacheCollector reads the block statistics of the current database when
+// scraped. Nothing is reported while the database is unreachable.
+type cacheCollector struct {
+	pool *pgxpool.Pool
+}
+
+func (c cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHits
+	ch <- cacheReads
+}
+
+func (c cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var hits, reads int64
+	err := c.pool.QueryRow(ctx, `
+		SELECT blks_hit, blks_read FROM pg_stat_database WHERE datname = current_database()
+	`).Scan(&hits, &reads)
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(cacheHits, prometheus.CounterValue, float64(hits))
+	ch <- prometheus.MustNewConstMetric(cacheReads, prometheus.CounterValue, float64(reads))
+}
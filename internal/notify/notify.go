@@ -0,0 +1,127 @@
+// Package notify fans data change events out to the app instances through
+// Postgres LISTEN/NOTIFY
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+	"truck-analytics-platform/internal/db"
+)
+
+// Channel is the Postgres notification channel events are published on
+const Channel = "data_events"
+
+// Event is a change of the data, see the webhooks package for the types
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Publish sends an event to every listening instance. Inside a transaction
+// it is sent on commit.
+func Publish(ctx context.Context, conn db.DB, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(ctx, `SELECT pg_notify($1, $2)`, Channel, string(payload))
+	return err
+}
+
+// Hub passes the events received by this instance to its subscribers
+type Hub struct {
+	start       sync.Once
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// Default is the hub of the app, it starts listening on first use
+var Default = &Hub{}
+
+// Subscribe returns a channel of events and a function to stop receiving
+// them. Events are dropped for subscribers that don't keep up.
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	h.start.Do(func() { go h.listen(context.Background()) })
+
+	ch := make(chan Event, 16)
+	h.mu.Lock()
+	if h.subscribers == nil {
+		h.subscribers = map[chan Event]struct{}{}
+	}
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
+
+func (h *Hub) broadcast(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			slog.Warn("Dropped event for slow subscriber", "event", e.ID)
+		}
+	}
+}
+
+// listen holds a connection listening on Channel, reconnecting with
+// backoff when it is lost
+func (h *Hub) listen(ctx context.Context) {
+	delay := time.Second
+	for {
+		err := h.receive(ctx, func() { delay = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Lost event notifications, reconnecting", "error", err, "in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 30*time.Second)
+	}
+}
+
+func (h *Hub) receive(ctx context.Context, listening func()) error {
+	pool, err := db.Connect()
+	if err != nil {
+		return err
+	}
+	acquired, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left in LISTEN state, don't give it back to the pool
+	conn := acquired.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, `LISTEN `+Channel); err != nil {
+		return err
+	}
+	listening()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			slog.Warn("Invalid event notification", "error", err)
+			continue
+		}
+		h.broadcast(e)
+	}
+}
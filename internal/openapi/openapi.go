@@ -0,0 +1,304 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema object as used by OpenAPI 3.0
+type Schema map[string]any
+
+// Document is an OpenAPI 3.0 document assembled from Go types
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Security   []map[string][]string           `json:"security,omitempty"`
+	Components Components                      `json:"components"`
+
+	// Overrides replaces reflected schemas for types with custom JSON encoding
+	overrides map[reflect.Type]Schema
+	// names are the component names of reflected structs
+	names map[reflect.Type]string
+}
+
+type Components struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	OperationID string              `json:"operationId,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+func New(title, version string) *Document {
+	d := &Document{
+		OpenAPI:   "3.0.3",
+		Info:      Info{Title: title, Version: version},
+		Paths:     make(map[string]map[string]Operation),
+		overrides: make(map[reflect.Type]Schema),
+		names:     make(map[reflect.Type]string),
+	}
+	d.Components.Schemas = make(map[string]Schema)
+	d.Components.SecuritySchemes = make(map[string]Schema)
+	return d
+}
+
+// Add registers an operation under a gin style path (/keys/:id)
+func (d *Document) Add(method, path string, op Operation) {
+	path = openAPIPath(path)
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(map[string]Operation)
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Define stores a named schema under components and returns a reference to it
+func (d *Document) Define(name string, s Schema) Schema {
+	d.Components.Schemas[name] = s
+	return Ref(name)
+}
+
+// RequireAPIKey declares a header API key scheme required by all operations
+func (d *Document) RequireAPIKey(name, header string) {
+	d.Components.SecuritySchemes[name] = Schema{"type": "apiKey", "in": "header", "name": header}
+	d.Security = append(d.Security, map[string][]string{name: {}})
+}
+
+// JSONBody describes a required JSON request body
+func JSONBody(s Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: s}},
+	}
+}
+
+// Override makes SchemaOf use s for values of type t
+func (d *Document) Override(t reflect.Type, s Schema) {
+	d.overrides[t] = s
+}
+
+// WithOverride uses s for values of type t while fn runs, then restores
+// the previous schema of t
+func (d *Document) WithOverride(t reflect.Type, s Schema, fn func()) {
+	previous, ok := d.overrides[t]
+	d.overrides[t] = s
+	defer func() {
+		if ok {
+			d.overrides[t] = previous
+		} else {
+			delete(d.overrides, t)
+		}
+	}()
+	fn()
+}
+
+func Ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// JSONResponse describes a response with a JSON body
+func JSONResponse(description string, s Schema) Response {
+	return Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: s}},
+	}
+}
+
+// SchemaOf reflects the JSON encoding of v. Named structs are stored under
+// components and referenced.
+func (d *Document) SchemaOf(v any) Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (d *Document) schemaOf(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	if s, ok := d.overrides[t]; ok {
+		return s
+	}
+	if t == rawMessageType {
+		// Any JSON value
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(d.schemaOf(t.Elem()))
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": d.schemaOf(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Struct:
+		if t == timeType {
+			return Schema{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name, ok := d.names[t]
+		if !ok {
+			name = d.componentName(t)
+			// Reserve the name first so recursive types terminate
+			d.names[t] = name
+			d.Components.Schemas[name] = Schema{}
+			d.Components.Schemas[name] = d.structSchema(t)
+		}
+		return Ref(name)
+	}
+
+	return Schema{}
+}
+
+// componentName names a struct after its type, prefixed with its package
+// when another package already has a type of that name, e.g. ForecastResult
+func (d *Document) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; !taken {
+		return name
+	}
+	pkg := path.Base(t.PkgPath())
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+func (d *Document) structSchema(t reflect.Type) Schema {
+	properties := map[string]Schema{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Untagged embedded structs are flattened by encoding/json
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := d.structSchema(f.Type)
+			for k, v := range embedded["properties"].(map[string]Schema) {
+				properties[k] = v
+			}
+			if r, ok := embedded["required"].([]string); ok {
+				required = append(required, r...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := d.schemaOf(f.Type)
+		if desc := f.Tag.Get("doc"); desc != "" {
+			s = withDescription(s, desc)
+		}
+		properties[name] = s
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	s := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// nullable marks a schema as accepting null. References can't carry
+// siblings in 3.0, so they are wrapped in allOf.
+func nullable(s Schema) Schema {
+	if _, ok := s["$ref"]; ok {
+		return Schema{"allOf": []Schema{s}, "nullable": true}
+	}
+	out := Schema{"nullable": true}
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+func withDescription(s Schema, desc string) Schema {
+	if _, ok := s["$ref"]; ok {
+		return Schema{"allOf": []Schema{s}, "description": desc}
+	}
+	out := Schema{"description": desc}
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// openAPIPath converts /keys/:id to /keys/{id}
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Inline reflects a struct without storing it under components, so the
+// current overrides apply to its fields
+func (d *Document) Inline(v any) Schema {
+	return d.structSchema(reflect.TypeOf(v))
+}
@@ -0,0 +1,304 @@
+// Package pivot runs caller-defined pivots over a registration table. Only
+// whitelisted columns are grouped and filtered on and filter values are
+// bound as parameters, so requests never reach the SQL text.
+package pivot
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/segments"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalid  = errors.New("invalid pivot")
+	ErrNoAccess = errors.New("API key has no access to this data")
+)
+
+// Dimensions that can be grouped and filtered on, besides the mass
+// segmentation columns (Mass_in_segment_N, Weight_in_segment_N) of the table
+var Dimensions = []string{"Federal_district", "Region", "Brand", "Wheel_formula", "Body_type", "Exact_mass", "Month_of_registration"}
+
+// Measures map a measure name to its aggregate
+var Measures = map[string]string{
+	"quantity": `SUM("Quantity")`,
+	"records":  `COUNT(*)`,
+}
+
+// MaxCells caps the groups a pivot may return
+const MaxCells = 20000
+
+// Request describes a pivot. Rows are the row dimensions, Column the
+// optional column dimension. Filters keep registrations whose dimension has
+// one of the values; brands match by canonical name.
+type Request struct {
+	// Dataset is a registration table, the latest one when empty
+	Dataset string `json:"dataset"`
+	// Segment limits the data to a market segment
+	Segment string              `json:"segment"`
+	Rows    []string            `json:"rows" binding:"required"`
+	Column  string              `json:"column"`
+	Measure string              `json:"measure"`
+	Filters map[string][]string `json:"filters"`
+}
+
+// Row is one row of a pivot. Values follows Result.Columns, nil where there
+// were no registrations.
+type Row struct {
+	Keys   []*string `json:"keys"`
+	Values []*int64  `json:"values,omitempty"`
+	Total  int64     `json:"total"`
+}
+
+type Result struct {
+	Dataset string   `json:"dataset"`
+	Segment string   `json:"segment,omitempty"`
+	Rows    []string `json:"rows"`
+	Column  string   `json:"column,omitempty"`
+	Measure string   `json:"measure"`
+	// Columns are the values of the column dimension
+	Columns []*string `json:"columns"`
+	Data    []Row     `json:"data"`
+	Totals  []int64   `json:"totals,omitempty"`
+	Total   int64     `json:"total"`
+}
+
+// Run validates a request against the table and the key permissions and
+// runs it
+func Run(ctx context.Context, conn db.DB, req Request, p auth.Permissions) (Result, error) {
+	if req.Measure == "" {
+		req.Measure = "quantity"
+	}
+	measure, ok := Measures[req.Measure]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: unknown measure %q", ErrInvalid, req.Measure)
+	}
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return Result{}, err
+	}
+	dataset, err := db.SelectDataset(datasets, req.Dataset, 0, 0)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	columns, err := db.DatasetColumns(ctx, conn, []db.Dataset{dataset})
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, err := allowedDimensions(ctx, conn, dataset, columns[dataset.Table])
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(req.Rows) == 0 {
+		return Result{}, fmt.Errorf("%w: at least one row dimension is required", ErrInvalid)
+	}
+	group := req.Rows
+	if req.Column != "" {
+		group = append(slices.Clip(group), req.Column)
+	}
+	for _, d := range group {
+		if !allowed[d] {
+			return Result{}, fmt.Errorf("%w: unknown dimension %q", ErrInvalid, d)
+		}
+	}
+
+	var where []string
+	var args []any
+	filter := func(dimension string, values []string) {
+		args = append(args, values)
+		where = append(where, fmt.Sprintf(`%s::text = ANY ($%d)`, expression(dimension), len(args)))
+	}
+
+	if req.Segment != "" {
+		s, ok := segments.Get(req.Segment)
+		if !ok {
+			return Result{}, fmt.Errorf("%w: unknown segment %q", ErrInvalid, req.Segment)
+		}
+		if !columns[dataset.Table][s.Mass.Column] {
+			return Result{}, fmt.Errorf("%w: segment %s needs column %s, missing in %s", ErrInvalid, s.Name, s.Mass.Column, dataset.Table)
+		}
+		where = append(where, s.Predicate())
+	}
+	if len(p.Segments) > 0 && !slices.Contains(p.Segments, req.Segment) {
+		return Result{}, fmt.Errorf("%w: the segment must be one of %s", ErrNoAccess, strings.Join(p.Segments, ", "))
+	}
+	if len(p.Years) > 0 && !slices.Contains(p.Years, dataset.Year) {
+		return Result{}, ErrNoAccess
+	}
+	if len(p.Brands) > 0 {
+		filter("Brand", p.Brands)
+	}
+
+	// Sorted for a stable query text
+	names := make([]string, 0, len(req.Filters))
+	for name := range req.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !allowed[name] {
+			return Result{}, fmt.Errorf("%w: unknown filter %q", ErrInvalid, name)
+		}
+		filter(name, req.Filters[name])
+	}
+
+	exprs := make([]string, len(group))
+	selects := make([]string, len(group))
+	for i, d := range group {
+		exprs[i] = expression(d)
+		selects[i] = exprs[i] + "::text"
+	}
+	sql := `SELECT ` + strings.Join(selects, ", ") + `, ` + measure + `
+		FROM ` + pgx.Identifier{dataset.Table}.Sanitize()
+	if len(where) > 0 {
+		sql += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	sql += ` GROUP BY ` + strings.Join(exprs, ", ") + ` ORDER BY ` + strings.Join(exprs, ", ") +
+		` LIMIT ` + strconv.Itoa(MaxCells+1)
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return Result{}, err
+	}
+	defer rows.Close()
+
+	result := Result{Dataset: dataset.Table, Segment: req.Segment, Rows: req.Rows, Column: req.Column, Measure: req.Measure,
+		Columns: []*string{}, Data: []Row{}}
+	type cell struct {
+		keys   []*string
+		column *string
+		value  int64
+	}
+	var cells []cell
+	for rows.Next() {
+		keys := make([]*string, len(group))
+		var value int64
+		dest := make([]any, 0, len(group)+1)
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		if err := rows.Scan(append(dest, &value)...); err != nil {
+			return Result{}, err
+		}
+
+		c := cell{keys: keys, value: value}
+		if req.Column != "" {
+			c.keys, c.column = keys[:len(req.Rows)], keys[len(req.Rows)]
+		}
+		cells = append(cells, c)
+	}
+	if err := rows.Err(); err != nil {
+		return Result{}, err
+	}
+	if len(cells) > MaxCells {
+		return Result{}, fmt.Errorf("%w: more than %d cells, add filters or fewer dimensions", ErrInvalid, MaxCells)
+	}
+
+	// Column values in their natural order, numbers as numbers
+	index := map[string]int{}
+	if req.Column != "" {
+		for _, c := range cells {
+			if _, ok := index[key(c.column)]; !ok {
+				index[key(c.column)] = 0
+				result.Columns = append(result.Columns, c.column)
+			}
+		}
+		slices.SortFunc(result.Columns, compareValues)
+		for i, c := range result.Columns {
+			index[key(c)] = i
+		}
+		result.Totals = make([]int64, len(result.Columns))
+	}
+
+	// Cells come ordered by the row keys, so rows are consecutive
+	for _, c := range cells {
+		if n := len(result.Data); n == 0 || !slices.EqualFunc(result.Data[n-1].Keys, c.keys, equalValues) {
+			row := Row{Keys: c.keys}
+			if req.Column != "" {
+				row.Values = make([]*int64, len(result.Columns))
+			}
+			result.Data = append(result.Data, row)
+		}
+		row := &result.Data[len(result.Data)-1]
+		row.Total += c.value
+		result.Total += c.value
+		if req.Column != "" {
+			i := index[key(c.column)]
+			v := c.value
+			row.Values[i] = &v
+			result.Totals[i] += c.value
+		}
+	}
+
+	return result, nil
+}
+
+// allowedDimensions are the whitelisted dimensions the table has
+func allowedDimensions(ctx context.Context, conn db.DB, dataset db.Dataset, columns map[string]bool) (map[string]bool, error) {
+	mass, err := db.MassSegmentColumns(ctx, conn, []db.Dataset{dataset})
+	if err != nil {
+		return nil, err
+	}
+	allowed := map[string]bool{}
+	for _, d := range append(slices.Clip(Dimensions), mass...) {
+		if columns[d] {
+			allowed[d] = true
+		}
+	}
+	return allowed, nil
+}
+
+// expression is the SQL of a whitelisted dimension. Brands are grouped by
+// canonical name.
+func expression(dimension string) string {
+	if dimension == "Brand" {
+		return `canonical_brand("Brand")`
+	}
+	return pgx.Identifier{dimension}.Sanitize()
+}
+
+func key(v *string) string {
+	if v == nil {
+		return "\x00null"
+	}
+	return *v
+}
+
+func equalValues(a, b *string) bool {
+	return key(a) == key(b)
+}
+
+// compareValues sorts numbers numerically and before text, nulls last
+func compareValues(a, b *string) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+	x, errX := strconv.ParseFloat(*a, 64)
+	y, errY := strconv.ParseFloat(*b, 64)
+	switch {
+	case errX == nil && errY == nil:
+		return cmp.Compare(x, y)
+	case errX == nil:
+		return -1
+	case errY == nil:
+		return 1
+	}
+	return strings.Compare(*a, *b)
+}
@@ -0,0 +1,314 @@
+package quality
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+
+	// Plausible gross vehicle mass of a registered truck, kg
+	MinMass = 3500
+	MaxMass = 100000
+
+	maxSamples = 20
+)
+
+// Sample is one offending value and how many rows carry it
+type Sample struct {
+	Value      *string `json:"value"`
+	Rows       int64   `json:"rows"`
+	Suggestion string  `json:"suggestion,omitempty"`
+}
+
+type Issue struct {
+	Check    string   `json:"check"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+	Rows     int64    `json:"rows"`
+	Samples  []Sample `json:"samples,omitempty"`
+}
+
+type DatasetReport struct {
+	Table  string  `json:"table"`
+	Rows   int64   `json:"rows"`
+	Issues []Issue `json:"issues"`
+}
+
+type Report struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Datasets    []DatasetReport `json:"datasets"`
+	Errors      int             `json:"errors"`
+	Warnings    int             `json:"warnings"`
+}
+
+// Check scans every registration table
+func Check(ctx context.Context, conn db.DB) (Report, error) {
+	report := Report{GeneratedAt: time.Now().UTC(), Datasets: []DatasetReport{}}
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return report, err
+	}
+	columns, err := db.DatasetColumns(ctx, conn, datasets)
+	if err != nil {
+		return report, err
+	}
+
+	for _, d := range datasets {
+		dr, err := checkDataset(ctx, conn, d, columns[d.Table])
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", d.Table, err)
+		}
+		for _, issue := range dr.Issues {
+			if issue.Severity == SeverityError {
+				report.Errors++
+			} else {
+				report.Warnings++
+			}
+		}
+		report.Datasets = append(report.Datasets, dr)
+	}
+
+	return report, nil
+}
+
+func checkDataset(ctx context.Context, conn db.DB, d db.Dataset, columns map[string]bool) (DatasetReport, error) {
+	dr := DatasetReport{Table: d.Table, Issues: []Issue{}}
+	table := pgx.Identifier{d.Table}.Sanitize()
+
+	if err := conn.QueryRow(ctx, `SELECT count(*) FROM `+table).Scan(&dr.Rows); err != nil {
+		return dr, err
+	}
+
+	checks := []func(context.Context, db.DB, db.Dataset, string, map[string]bool) ([]Issue, error){
+		checkGeography,
+		checkBrands,
+		checkMass,
+		checkMonths,
+		checkQuantity,
+		checkDuplicates,
+	}
+	for _, check := range checks {
+		issues, err := check(ctx, conn, d, table, columns)
+		if err != nil {
+			return dr, err
+		}
+		for _, issue := range issues {
+			if issue.Rows > 0 {
+				dr.Issues = append(dr.Issues, issue)
+			}
+		}
+	}
+
+	return dr, nil
+}
+
+// checkGeography validates districts and regions against the directory and
+// that every region sits in its own district
+func checkGeography(ctx context.Context, conn db.DB, _ db.Dataset, table string, columns map[string]bool) ([]Issue, error) {
+	if !columns["Federal_district"] || !columns["Region"] {
+		return nil, nil
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT "Federal_district"::text, "Region"::text, count(*)
+		FROM `+table+`
+		GROUP BY 1, 2
+		ORDER BY 3 DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unknownDistrict := Issue{Check: "unknown_district", Severity: SeverityError, Message: "Federal_district is missing or not a federal district"}
+	unknownRegion := Issue{Check: "unknown_region", Severity: SeverityError, Message: "Region is missing or not a federal subject"}
+	wrongDistrict := Issue{Check: "region_district_mismatch", Severity: SeverityError, Message: "Region is listed under another federal district"}
+
+	for rows.Next() {
+		var district, region *string
+		var n int64
+		if err := rows.Scan(&district, &region, &n); err != nil {
+			return nil, err
+		}
+
+		d, districtOK := geo.District{}, false
+		if district != nil {
+			d, districtOK = geo.FindDistrict(*district)
+		}
+		if !districtOK {
+			add(&unknownDistrict, Sample{Value: district, Rows: n})
+		}
+
+		r, regionOK := geo.Region{}, false
+		if region != nil {
+			r, regionOK = geo.FindRegion(*region)
+		}
+		switch {
+		case !regionOK:
+			add(&unknownRegion, Sample{Value: region, Rows: n})
+		case region != nil && *region != r.Name:
+			// Known under another spelling, reports will split it
+			add(&unknownRegion, Sample{Value: region, Rows: n, Suggestion: r.Name})
+		case districtOK && r.District != d.Code:
+			expected, _ := geo.FindDistrict(r.District)
+			add(&wrongDistrict, Sample{Value: region, Rows: n, Suggestion: expected.Name})
+		}
+	}
+
+	return []Issue{unknownDistrict, unknownRegion, wrongDistrict}, rows.Err()
+}
+
+// checkBrands lists raw brand values missing from the brand dictionary.
+// Their registrations are reported under the raw value, if at all.
+func checkBrands(ctx context.Context, conn db.DB, _ db.Dataset, table string, columns map[string]bool) ([]Issue, error) {
+	if !columns["Brand"] {
+		return nil, nil
+	}
+
+	samples, err := grouped(ctx, conn, `
+		SELECT "Brand"::text, count(*) FROM `+table+`
+		WHERE "Brand" IS NULL OR NOT EXISTS (SELECT 1 FROM brands WHERE name = canonical_brand("Brand"))
+		GROUP BY 1 ORDER BY 2 DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	issue := Issue{Check: "unmapped_brand", Severity: SeverityWarning, Message: "Brand is missing or not in the brand dictionary"}
+	for _, s := range samples {
+		add(&issue, s)
+	}
+	return []Issue{issue}, nil
+}
+
+func checkMass(ctx context.Context, conn db.DB, _ db.Dataset, table string, columns map[string]bool) ([]Issue, error) {
+	if !columns["Exact_mass"] {
+		return nil, nil
+	}
+
+	missing := Issue{Check: "missing_mass", Severity: SeverityWarning, Message: "Exact_mass is null, the row can't be put in a mass segment"}
+	if err := conn.QueryRow(ctx, `SELECT count(*) FROM `+table+` WHERE "Exact_mass" IS NULL`).Scan(&missing.Rows); err != nil {
+		return nil, err
+	}
+
+	samples, err := grouped(ctx, conn, `
+		SELECT "Exact_mass"::text, count(*) FROM `+table+`
+		WHERE "Exact_mass" < $1 OR "Exact_mass" > $2
+		GROUP BY 1 ORDER BY 2 DESC
+	`, MinMass, MaxMass)
+	if err != nil {
+		return nil, err
+	}
+	outOfRange := Issue{Check: "mass_out_of_range", Severity: SeverityWarning,
+		Message: fmt.Sprintf("Exact_mass is outside %d-%d kg", MinMass, MaxMass)}
+	for _, s := range samples {
+		add(&outOfRange, s)
+	}
+
+	return []Issue{missing, outOfRange}, nil
+}
+
+// checkMonths flags months outside the period the table name promises
+func checkMonths(ctx context.Context, conn db.DB, d db.Dataset, table string, columns map[string]bool) ([]Issue, error) {
+	if !columns["Month_of_registration"] {
+		return nil, nil
+	}
+
+	samples, err := grouped(ctx, conn, `
+		SELECT "Month_of_registration"::text, count(*) FROM `+table+`
+		WHERE "Month_of_registration" IS NULL OR "Month_of_registration" NOT BETWEEN $1 AND $2
+		GROUP BY 1 ORDER BY 2 DESC
+	`, d.FromMonth, d.ToMonth)
+	if err != nil {
+		return nil, err
+	}
+
+	issue := Issue{Check: "month_out_of_range", Severity: SeverityError,
+		Message: fmt.Sprintf("Month_of_registration is missing or outside %d-%d", d.FromMonth, d.ToMonth)}
+	for _, s := range samples {
+		add(&issue, s)
+	}
+	return []Issue{issue}, nil
+}
+
+func checkQuantity(ctx context.Context, conn db.DB, _ db.Dataset, table string, columns map[string]bool) ([]Issue, error) {
+	if !columns["Quantity"] {
+		return nil, nil
+	}
+
+	samples, err := grouped(ctx, conn, `
+		SELECT "Quantity"::text, count(*) FROM `+table+`
+		WHERE "Quantity" IS NULL OR "Quantity" < 0
+		GROUP BY 1 ORDER BY 2 DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	issue := Issue{Check: "invalid_quantity", Severity: SeverityError, Message: "Quantity is missing or negative"}
+	for _, s := range samples {
+		add(&issue, s)
+	}
+	return []Issue{issue}, nil
+}
+
+// checkDuplicates counts rows repeated in every column. Rows counts the
+// extra copies.
+func checkDuplicates(ctx context.Context, conn db.DB, _ db.Dataset, table string, columns map[string]bool) ([]Issue, error) {
+	var cols []string
+	for c := range columns {
+		cols = append(cols, pgx.Identifier{c}.Sanitize())
+	}
+	if len(cols) == 0 {
+		return nil, nil
+	}
+
+	issue := Issue{Check: "duplicate_rows", Severity: SeverityWarning, Message: "Rows repeated in every column"}
+	err := conn.QueryRow(ctx, `
+		SELECT COALESCE(SUM(n - 1), 0) FROM (
+			SELECT count(*) AS n FROM `+table+`
+			GROUP BY `+strings.Join(cols, ", ")+`
+			HAVING count(*) > 1
+		) duplicates
+	`).Scan(&issue.Rows)
+
+	return []Issue{issue}, err
+}
+
+// grouped runs a query returning (value, count) pairs
+func grouped(ctx context.Context, conn db.DB, sql string, args ...any) ([]Sample, error) {
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []Sample
+	for rows.Next() {
+		var s Sample
+		if err := rows.Scan(&s.Value, &s.Rows); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// add counts the rows of a sample and keeps the first samples
+func add(issue *Issue, s Sample) {
+	issue.Rows += s.Rows
+	if len(issue.Samples) < maxSamples {
+		issue.Samples = append(issue.Samples, s)
+	}
+}
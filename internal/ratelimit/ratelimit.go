@@ -0,0 +1,280 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Limit is a token bucket: RPS tokens per second, up to Burst at once
+type Limit struct {
+	RPS   float64
+	Burst int
+}
+
+type Config struct {
+	// Client applies per address to every API request before the key is
+	// checked, so unauthenticated traffic is limited too
+	Client Limit
+	// Default applies to light routes, Heavy to report queries
+	Default Limit
+	Heavy   Limit
+	// Routes overrides the limit of single routes, keyed by route pattern
+	Routes map[string]Limit
+	// Concurrency caps heavy queries running at once across all clients
+	Concurrency int
+	// QueueWait is how long a heavy request may wait for a free slot
+	QueueWait time.Duration
+}
+
+// ConfigFromEnv reads RATE_LIMIT_CLIENT, RATE_LIMIT (default limit),
+// RATE_LIMIT_HEAVY, RATE_LIMIT_ROUTES ("/path=rps:burst,..."),
+// HEAVY_CONCURRENCY and HEAVY_QUEUE_WAIT_MS. Limits are written as rps:burst.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Client:      Limit{RPS: 20, Burst: 40},
+		Default:     Limit{RPS: 10, Burst: 20},
+		Heavy:       Limit{RPS: 1, Burst: 5},
+		Routes:      map[string]Limit{},
+		Concurrency: 8,
+		QueueWait:   2 * time.Second,
+	}
+	if l, ok := parseLimit(os.Getenv("RATE_LIMIT_CLIENT")); ok {
+		cfg.Client = l
+	}
+	if l, ok := parseLimit(os.Getenv("RATE_LIMIT")); ok {
+		cfg.Default = l
+	}
+	if l, ok := parseLimit(os.Getenv("RATE_LIMIT_HEAVY")); ok {
+		cfg.Heavy = l
+	}
+	for _, entry := range strings.Split(os.Getenv("RATE_LIMIT_ROUTES"), ",") {
+		route, limit, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if l, ok := parseLimit(limit); ok && route != "" {
+			cfg.Routes[route] = l
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("HEAVY_CONCURRENCY")); err == nil && n > 0 {
+		cfg.Concurrency = n
+	}
+	if ms, err := strconv.Atoi(os.Getenv("HEAVY_QUEUE_WAIT_MS")); err == nil && ms >= 0 {
+		cfg.QueueWait = time.Duration(ms) * time.Millisecond
+	}
+	return cfg
+}
+
+func parseLimit(s string) (Limit, bool) {
+	rps, burst, ok := strings.Cut(s, ":")
+	if !ok {
+		return Limit{}, false
+	}
+	r, err1 := strconv.ParseFloat(rps, 64)
+	b, err2 := strconv.Atoi(burst)
+	if err1 != nil || err2 != nil || r <= 0 || b <= 0 {
+		return Limit{}, false
+	}
+	return Limit{RPS: r, Burst: b}, true
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func reject(ctx *gin.Context, reason string, retryAfter time.Duration) {
+	metrics.ObserveRateLimited(ctx.FullPath(), reason)
+
+	seconds := RetrySeconds(retryAfter)
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+
+	message := "Too many requests, retry in " + strconv.Itoa(seconds) + "s"
+	ctx.Error(errors.New(message))
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse{Error: message, RequestID: logging.RequestID(ctx)})
+}
+
+// RetrySeconds rounds a wait up to whole seconds, at least one
+func RetrySeconds(retryAfter time.Duration) int {
+	return max(int(math.Ceil(retryAfter.Seconds())), 1)
+}
+
+// Guard rate limits clients per route and caps concurrent heavy queries.
+// The gRPC server shares it with the HTTP API.
+type Guard struct {
+	cfg   Config
+	slots chan struct{}
+
+	clients *buckets
+
+	mu     sync.Mutex
+	routes map[string]*buckets
+}
+
+// Default is the guard of the app, configured from the environment
+var Default = sync.OnceValue(func() *Guard {
+	return NewGuard(ConfigFromEnv())
+})
+
+func NewGuard(cfg Config) *Guard {
+	return &Guard{
+		cfg:     cfg,
+		slots:   make(chan struct{}, cfg.Concurrency),
+		clients: newBuckets(cfg.Client),
+		routes:  make(map[string]*buckets),
+	}
+}
+
+// Client limits requests per address across all routes. It goes before
+// authentication, which costs a database lookup.
+func (g *Guard) Client(ctx *gin.Context) {
+	r := g.clients.get("ip:" + ctx.ClientIP()).Reserve()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		reject(ctx, "client", delay)
+		return
+	}
+	ctx.Next()
+}
+
+// Light applies the default limit, or the route override
+func (g *Guard) Light(ctx *gin.Context) {
+	g.limit(ctx, false)
+}
+
+// Heavy applies the heavy limit, or the route override
+func (g *Guard) Heavy(ctx *gin.Context) {
+	g.limit(ctx, true)
+}
+
+func (g *Guard) limit(ctx *gin.Context, heavy bool) {
+	if delay, ok := g.Take(ctx.FullPath(), clientID(ctx), heavy); !ok {
+		reject(ctx, "rate", delay)
+		return
+	}
+	ctx.Next()
+}
+
+// Take takes a token from the bucket of a client on a route, limited by the
+// heavy or default limit unless the route is overridden. Without a token
+// left it returns how long to wait for one.
+func (g *Guard) Take(route, client string, heavy bool) (time.Duration, bool) {
+	g.mu.Lock()
+	b, ok := g.routes[route]
+	if !ok {
+		limit := g.cfg.Default
+		if heavy {
+			limit = g.cfg.Heavy
+		}
+		if l, ok := g.cfg.Routes[route]; ok {
+			limit = l
+		}
+		b = newBuckets(limit)
+		g.routes[route] = b
+	}
+	g.mu.Unlock()
+
+	r := b.get(client).Reserve()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		return delay, false
+	}
+	return 0, true
+}
+
+// Queue holds the request until one of the shared query slots is free, or
+// rejects it after QueueWait
+func (g *Guard) Queue(ctx *gin.Context) {
+	release, ok := g.Acquire(ctx.Request.Context())
+	if !ok {
+		reject(ctx, "concurrency", time.Second)
+		return
+	}
+	defer release()
+
+	ctx.Next()
+}
+
+// Acquire waits up to QueueWait for a query slot. The slot is held until
+// release is called.
+func (g *Guard) Acquire(ctx context.Context) (release func(), ok bool) {
+	wait, cancel := context.WithTimeout(ctx, g.cfg.QueueWait)
+	defer cancel()
+
+	select {
+	case g.slots <- struct{}{}:
+		return func() { <-g.slots }, true
+	case <-wait.Done():
+		return nil, false
+	}
+}
+
+// clientID identifies the caller by API key, or by address without one
+func clientID(ctx *gin.Context) string {
+	if key, ok := auth.FromContext(ctx); ok {
+		return KeyClient(key)
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// KeyClient is the client ID of a caller with an API key
+func KeyClient(key auth.Key) string {
+	if key.ID == 0 {
+		return "key:" + key.Name
+	}
+	return "key:" + strconv.FormatInt(key.ID, 10)
+}
+
+// buckets holds one token bucket per client. Buckets idle for a while are
+// dropped, a full bucket is the same as a new one.
+type buckets struct {
+	limit Limit
+
+	mu      sync.Mutex
+	clients map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+const idleBucket = 10 * time.Minute
+
+func newBuckets(limit Limit) *buckets {
+	return &buckets{limit: limit, clients: make(map[string]*bucket), swept: time.Now()}
+}
+
+func (b *buckets) get(client string) *rate.Limiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.swept) > idleBucket {
+		for id, c := range b.clients {
+			if now.Sub(c.seen) > idleBucket {
+				delete(b.clients, id)
+			}
+		}
+		b.swept = now
+	}
+
+	c, ok := b.clients[client]
+	if !ok {
+		c = &bucket{limiter: rate.NewLimiter(rate.Limit(b.limit.RPS), b.limit.Burst)}
+		b.clients[client] = c
+	}
+	c.seen = now
+	return c.limiter
+}
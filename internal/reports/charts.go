@@ -0,0 +1,138 @@
+package reports
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"truck-analytics-platform/internal/charts"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Chart kinds served under <report>/chart/:kind
+const (
+	ChartDistricts = "districts"
+	ChartShares    = "shares"
+	ChartMonthly   = "monthly"
+)
+
+var ChartKinds = []string{ChartDistricts, ChartShares, ChartMonthly}
+
+var monthNames = []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// monthName labels a month, out of range months keep their number
+func monthName(month int) string {
+	if month < 1 || month > len(monthNames) {
+		return strconv.Itoa(month)
+	}
+	return monthNames[month-1]
+}
+
+// ServeChart renders a report as an image, SVG by default or PNG with
+// ?format=png:
+//
+//	districts - registrations of every brand per federal district
+//	shares    - brand shares of every federal district, stacked to 100%
+//	monthly   - registrations of every brand per month
+func ServeChart(ctx *gin.Context, q Query) {
+	kind := ctx.Param("kind")
+	if !slices.Contains(ChartKinds, kind) {
+		fail(ctx, http.StatusBadRequest, "Unknown chart "+kind)
+		return
+	}
+	format := ctx.DefaultQuery("format", charts.FormatSVG)
+	if format != charts.FormatSVG && format != charts.FormatPNG {
+		fail(ctx, http.StatusBadRequest, "Unknown format "+format)
+		return
+	}
+
+	q, conn, ok := prepare(ctx, q, slog.String("chart", kind), slog.String("format", format))
+	if !ok {
+		return
+	}
+
+	queryCtx := tracing.WithOperation(ctx.Request.Context(), "report "+q.Name())
+	var chart charts.Chart
+	if kind == ChartMonthly {
+		monthly, err := Monthly(queryCtx, conn, q)
+		if err != nil {
+			fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+			return
+		}
+		chart = monthlyChart(q, monthly)
+	} else {
+		data, err := Run(queryCtx, conn, q)
+		if err != nil {
+			fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+			return
+		}
+		chart = districtChart(q, data, kind == ChartShares)
+	}
+
+	_, span := tracing.Start(ctx.Request.Context(), "render chart")
+	image, contentType, err := charts.Render(chart, format)
+	span.End()
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "Failed to render chart: "+err.Error())
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, image)
+}
+
+// districtChart plots the district total rows of a report, districts in
+// directory order
+func districtChart(q Query, data map[string][]TruckAnalytics, shares bool) charts.Chart {
+	chart := charts.Chart{Kind: charts.Bars, Title: q.Title() + ", registrations by federal district"}
+	if shares {
+		chart.Kind = charts.Stacked
+		chart.Title = q.Title() + ", brand shares by federal district"
+	}
+
+	var totals []TruckAnalytics
+	for _, d := range geo.Districts {
+		for name, rows := range data {
+			if found, ok := geo.FindDistrict(name); !ok || found.Code != d.Code {
+				continue
+			}
+			for _, row := range rows {
+				if row.RegionName == name {
+					chart.Categories = append(chart.Categories, d.Short)
+					totals = append(totals, row)
+				}
+			}
+		}
+	}
+
+	// Rows carry every brand of the report in the same order
+	if len(totals) > 0 {
+		for j, b := range totals[0].Brands {
+			values := make([]float64, len(totals))
+			for i, row := range totals {
+				if q := row.Brands[j].Quantity; q != nil {
+					values[i] = float64(*q)
+				}
+			}
+			chart.Series = append(chart.Series, charts.Series{Name: b.Brand, Values: values})
+		}
+	}
+	return chart
+}
+
+func monthlyChart(q Query, m MonthlyVolumes) charts.Chart {
+	chart := charts.Chart{Kind: charts.Lines, Title: q.Title() + ", registrations by month"}
+	for _, month := range m.Months {
+		chart.Categories = append(chart.Categories, monthName(month))
+	}
+	for i, brand := range m.Brands {
+		values := make([]float64, len(m.Months))
+		for j, v := range m.Volumes[i] {
+			values[j] = float64(v)
+		}
+		chart.Series = append(chart.Series, charts.Series{Name: brand, Values: values})
+	}
+	return chart
+}
@@ -0,0 +1,88 @@
+package reports
+
+import "truck-analytics-platform/internal/db"
+
+// PreviousYear is the report of the same months of the year before, false
+// when that year isn't loaded. Months 0 compares every month loaded for
+// the current year, and the previous year has to cover them all.
+func (q Query) PreviousYear(datasets []db.Dataset) (Query, bool) {
+	current, ok := db.ParseDataset(q.Dataset)
+	if !ok {
+		return Query{}, false
+	}
+	months := current.ToMonth
+	if q.Months > 0 && q.Months < months {
+		months = q.Months
+	}
+	d, ok := db.PickDataset(datasets, current.Year-1, months)
+	if !ok || d.ToMonth < months {
+		return Query{}, false
+	}
+
+	previous := q
+	previous.Dataset = d.Table
+	previous.Months = months
+	return previous, true
+}
+
+// Change is a number of registrations with the same months of the year
+// before, Previous is nil without data for it
+type Change struct {
+	Current  int
+	Previous *int
+}
+
+// Delta is the year over year change, false when there is nothing to compare with
+func (c Change) Delta() (float64, bool) {
+	if c.Previous == nil || *c.Previous == 0 {
+		return 0, false
+	}
+	return float64(c.Current-*c.Previous) / float64(*c.Previous), true
+}
+
+// BrandTotals sums the district totals of report data per brand, the empty
+// brand being the report total
+func BrandTotals(data map[string][]TruckAnalytics) map[string]int {
+	totals := map[string]int{"": 0}
+	for district, rows := range data {
+		for _, ta := range rows {
+			if ta.RegionName != district {
+				continue
+			}
+			for _, b := range ta.Brands {
+				if b.Quantity != nil {
+					totals[b.Brand] += *b.Quantity
+				}
+			}
+			totals[""] += ta.Total
+		}
+	}
+	return totals
+}
+
+// Comparison holds the brand totals of a report and of the same months
+// the year before, Previous is nil when they aren't loaded
+type Comparison struct {
+	Current  map[string]int
+	Previous map[string]int
+}
+
+// Compare sums the brand totals of report data and of the previous year's,
+// nil when it isn't loaded
+func Compare(current, previous map[string][]TruckAnalytics) Comparison {
+	c := Comparison{Current: BrandTotals(current)}
+	if previous != nil {
+		c.Previous = BrandTotals(previous)
+	}
+	return c
+}
+
+// Brand is the change of a brand, the empty brand being the report total
+func (c Comparison) Brand(brand string) Change {
+	change := Change{Current: c.Current[brand]}
+	if c.Previous != nil {
+		previous := c.Previous[brand]
+		change.Previous = &previous
+	}
+	return change
+}
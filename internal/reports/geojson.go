@@ -0,0 +1,129 @@
+package reports
+
+import (
+	"encoding/json"
+
+	"truck-analytics-platform/internal/geo"
+)
+
+// FeatureCollection is a report as GeoJSON: one feature per region of the
+// directory, keyed by region code, for choropleth maps
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Report   string    `json:"report"`
+	Brands   []string  `json:"brands"`
+	Total    int       `json:"total"`
+	Features []Feature `json:"features"`
+	// Unmatched lists report regions missing from the directory
+	Unmatched []string `json:"unmatched"`
+}
+
+type Feature struct {
+	Type       string          `json:"type"`
+	ID         string          `json:"id"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// GeoJSON attaches report rows to the region boundaries, it fails with
+// geo.ErrNoBoundaries without them. Properties are flat so map styles can
+// use them directly:
+//
+//	code, name, name_en, district, total, share (of the country total),
+//	<brand> (registrations, null when none), <brand>_share (of the region total)
+//
+// Rows of different spellings of a region are added up. Regions without
+// registrations are included with zero totals, regions missing from the
+// boundaries file with a null geometry.
+func GeoJSON(q Query, data map[string][]TruckAnalytics) (FeatureCollection, error) {
+	geometries, err := geo.Boundaries()
+	if err != nil {
+		return FeatureCollection{}, err
+	}
+
+	byCode := map[string]TruckAnalytics{}
+	fc := FeatureCollection{Type: "FeatureCollection", Report: q.Name(), Brands: []string{}, Features: []Feature{}, Unmatched: []string{}}
+
+	for district, rows := range data {
+		for _, row := range rows {
+			if row.RegionName == district {
+				continue
+			}
+			if row.RegionCode == "" {
+				fc.Unmatched = append(fc.Unmatched, row.RegionName)
+				continue
+			}
+			byCode[row.RegionCode] = addRows(byCode[row.RegionCode], row)
+			fc.Total += row.Total
+			if len(fc.Brands) == 0 {
+				for _, b := range row.Brands {
+					fc.Brands = append(fc.Brands, BrandKey(b.Brand))
+				}
+			}
+		}
+	}
+
+	for _, r := range geo.Regions {
+		district, _ := geo.FindDistrict(r.District)
+		props := map[string]any{
+			"code":     r.Code,
+			"name":     r.Name,
+			"name_en":  r.NameEn,
+			"district": district.Name,
+			"total":    0,
+			"share":    0.0,
+		}
+		for _, brand := range fc.Brands {
+			props[brand] = nil
+			props[brand+"_share"] = 0.0
+		}
+
+		if row, ok := byCode[r.Code]; ok {
+			props["total"] = row.Total
+			props["share"] = share(row.Total, fc.Total)
+			for _, b := range row.Brands {
+				if b.Quantity != nil {
+					props[BrandKey(b.Brand)] = *b.Quantity
+					props[BrandKey(b.Brand)+"_share"] = share(*b.Quantity, row.Total)
+				}
+			}
+		}
+
+		geometry := geometries[r.Code]
+		if geometry == nil {
+			geometry = json.RawMessage("null")
+		}
+		fc.Features = append(fc.Features, Feature{Type: "Feature", ID: r.Code, Geometry: geometry, Properties: props})
+	}
+
+	return fc, nil
+}
+
+// addRows adds the volumes of a report row to another of the same region
+func addRows(sum, row TruckAnalytics) TruckAnalytics {
+	if sum.Brands == nil {
+		sum = TruckAnalytics{RegionName: row.RegionName, RegionCode: row.RegionCode, Brands: make([]BrandVolume, len(row.Brands))}
+		for i, b := range row.Brands {
+			sum.Brands[i].Brand = b.Brand
+		}
+	}
+	sum.Total += row.Total
+	for i, b := range row.Brands {
+		if b.Quantity == nil {
+			continue
+		}
+		q := *b.Quantity
+		if sum.Brands[i].Quantity != nil {
+			q += *sum.Brands[i].Quantity
+		}
+		sum.Brands[i].Quantity = &q
+	}
+	return sum
+}
+
+func share(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
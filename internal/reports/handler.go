@@ -0,0 +1,107 @@
+package reports
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/segments"
+	"truck-analytics-platform/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Formats a report can be written in, chosen with ?format=
+const (
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+)
+
+// Serve runs a report and writes it as a Response, or in the format asked
+// for with ?format=
+func Serve(ctx *gin.Context, q Query) {
+	format := ctx.DefaultQuery("format", FormatJSON)
+	if format != FormatJSON && format != FormatGeoJSON {
+		fail(ctx, http.StatusBadRequest, "Unknown format "+format)
+		return
+	}
+	if _, err := geo.Boundaries(); format == FormatGeoJSON && err != nil {
+		fail(ctx, http.StatusNotImplemented, err.Error())
+		return
+	}
+
+	q, conn, ok := prepare(ctx, q, slog.String("format", format))
+	if !ok {
+		return
+	}
+
+	queryCtx := tracing.WithOperation(ctx.Request.Context(), "report "+q.Name())
+	data, err := Run(queryCtx, conn, q)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "Failed to execute query: "+err.Error())
+		return
+	}
+
+	rows := 0
+	for _, regions := range data {
+		rows += len(regions)
+	}
+	logging.Annotate(ctx, slog.Int("rows", rows))
+
+	switch format {
+	case FormatGeoJSON:
+		fc, err := GeoJSON(q, data)
+		if err != nil {
+			fail(ctx, http.StatusNotImplemented, err.Error())
+			return
+		}
+		ctx.Header("Content-Type", "application/geo+json; charset=utf-8")
+		tracing.JSON(ctx, http.StatusOK, fc)
+	default:
+		tracing.JSON(ctx, http.StatusOK, Response{Data: data})
+	}
+}
+
+// prepare annotates the access log with the report, narrows it to the
+// brands the API key may see and gets a connection. It writes the error
+// response and returns false when the report can't run.
+func prepare(ctx *gin.Context, q Query, params ...slog.Attr) (Query, db.DB, bool) {
+	attrs := []any{
+		slog.String("dataset", q.Dataset),
+		slog.String("segment", q.Segment),
+		slog.Int("months", q.Months),
+	}
+	for _, p := range params {
+		attrs = append(attrs, p)
+	}
+	logging.Annotate(ctx, slog.String("report", q.Name()), slog.Group("params", attrs...))
+
+	// Keys limited to some brands only see those columns
+	if key, ok := auth.FromContext(ctx); ok {
+		segment, _ := segments.Get(q.Segment)
+		q.Brands = key.Permissions.AllowedBrands(q.brands(segment))
+		if len(q.Brands) == 0 {
+			fail(ctx, http.StatusForbidden, "API key has no access to the brands of this report")
+			return q, nil, false
+		}
+	}
+
+	conn, err := db.Open(ctx.Request.Context())
+	if err != nil {
+		slog.WarnContext(ctx.Request.Context(), "Can't connect to database")
+		fail(ctx, http.StatusServiceUnavailable, "Database is unavailable")
+		return q, nil, false
+	}
+
+	return q, conn, true
+}
+
+// fail writes an error envelope carrying the request ID and records the
+// error for the access log
+func fail(ctx *gin.Context, status int, message string) {
+	ctx.Error(errors.New(message))
+	ctx.JSON(status, Response{Error: message, RequestID: logging.RequestID(ctx)})
+}
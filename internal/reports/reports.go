@@ -0,0 +1,373 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/metrics"
+	"truck-analytics-platform/internal/segments"
+)
+
+// Query selects a segment report: registrations of one segment in one
+// dataset, optionally limited to the first Months months of the year
+type Query struct {
+	Dataset string `json:"dataset"`
+	Segment string `json:"segment"`
+	Months  int    `json:"months,omitempty"`
+	// Brands overrides the segment brand columns
+	Brands []string `json:"brands,omitempty"`
+}
+
+// Name identifies the report in logs and metrics, e.g.
+// truck_analytics_2023_01_12/tractors4x2/9m
+func (q Query) Name() string {
+	name := q.Dataset + "/" + q.Segment
+	if q.Months > 0 {
+		name += fmt.Sprintf("/%dm", q.Months)
+	}
+	return name
+}
+
+// Title describes the report for people, e.g. "tractors4x2, 2023, months 1-9"
+func (q Query) Title() string {
+	d, ok := db.ParseDataset(q.Dataset)
+	if !ok {
+		return q.Segment + ", " + q.Dataset
+	}
+	to := d.ToMonth
+	if q.Months > 0 && q.Months < to {
+		to = q.Months
+	}
+	return fmt.Sprintf("%s, %d, months %d-%d", q.Segment, d.Year, d.FromMonth, to)
+}
+
+// BrandVolume is the number of registrations of a brand, nil when there were none
+type BrandVolume struct {
+	Brand    string
+	Quantity *int
+}
+
+// TruckAnalytics is one region of a report. Every federal district is
+// followed by a row with the district totals, named after the district.
+// RegionCode is the region or district code from the geo directory, empty
+// when the name is unknown.
+type TruckAnalytics struct {
+	RegionName string
+	RegionCode string
+	Brands     []BrandVolume
+	Total      int
+}
+
+// Response wraps report data grouped by federal district
+type Response struct {
+	Data      map[string][]TruckAnalytics `json:"data"`
+	Error     string                      `json:"error,omitempty"`
+	RequestID string                      `json:"request_id,omitempty"`
+}
+
+// BrandKey is the JSON property name of a brand column
+func BrandKey(brand string) string {
+	return strings.ToLower(brand)
+}
+
+// MarshalJSON renders brands as flat properties between the region and total:
+// {"region_name": "...", "region_code": "RU-MOW", "faw": 10, "howo": null, "total": 10}
+func (ta TruckAnalytics) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"region_name":`)
+	name, err := json.Marshal(ta.RegionName)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(name)
+
+	buf.WriteString(`,"region_code":`)
+	if ta.RegionCode == "" {
+		buf.WriteString("null")
+	} else {
+		code, _ := json.Marshal(ta.RegionCode)
+		buf.Write(code)
+	}
+
+	for _, b := range ta.Brands {
+		key, _ := json.Marshal(BrandKey(b.Brand))
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		if b.Quantity == nil {
+			buf.WriteString("null")
+		} else {
+			fmt.Fprintf(&buf, "%d", *b.Quantity)
+		}
+	}
+
+	fmt.Fprintf(&buf, `,"total":%d}`, ta.Total)
+	return buf.Bytes(), nil
+}
+
+// brands returns the brand columns of a report
+func (q Query) brands(s segments.Segment) []string {
+	if len(q.Brands) > 0 {
+		return q.Brands
+	}
+	return s.Brands
+}
+
+// reportQuery takes region and district codes from the aggregates, which
+// join the raw names on the region directory, see migrations/0003_regions.sql
+const reportQuery = `
+	WITH base_data AS (
+		SELECT 
+			"Federal_district",
+			"Region",
+			"Brand",
+			MIN("District_code") as district_code,
+			MIN("Region_code") as region_code,
+			SUM("Quantity") as total_sales
+		FROM ` + db.AggregatesView + `
+		WHERE 
+			"Dataset" = $1
+			AND "Segment" = $2
+			AND "Brand" = ANY($3)
+			AND ($4 = 0 OR "Month_of_registration" <= $4)
+		GROUP BY 
+			"Federal_district", 
+			"Region", 
+			"Brand"
+	),
+	federal_totals AS (
+		SELECT 
+			"Federal_district",
+			"Federal_district" as "Region",
+			"Brand",
+			MIN(district_code) as district_code,
+			MIN(district_code) as region_code,
+			SUM(total_sales) as total_sales
+		FROM base_data
+		GROUP BY "Federal_district", "Brand"
+	),
+	combined_data AS (
+		SELECT * FROM base_data
+		UNION ALL
+		SELECT * FROM federal_totals
+	)
+	SELECT 
+		"Federal_district",
+		COALESCE("Region", "Federal_district") as Region_name,
+		MIN(region_code) as region_code,
+		"Brand",
+		SUM(total_sales)::bigint as total_sales
+	FROM combined_data
+	GROUP BY 
+		"Federal_district",
+		"Region",
+		"Brand"
+	ORDER BY 
+		"Federal_district",
+		CASE 
+			WHEN "Region" = "Federal_district" THEN 1 
+			ELSE 0 
+		END,
+		"Region"
+`
+
+// Run executes a segment report and groups regions by federal district
+func Run(ctx context.Context, conn db.DB, q Query) (map[string][]TruckAnalytics, error) {
+	// Map for grouping data by federal district
+	dataByDistrict := make(map[string][]TruckAnalytics)
+	err := Each(ctx, conn, q, func(district string, ta TruckAnalytics) error {
+		dataByDistrict[district] = append(dataByDistrict[district], ta)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dataByDistrict, nil
+}
+
+// Each executes a segment report and passes the regions to fn as they are
+// read, ordered by federal district, each district followed by its totals.
+// An error from fn stops the query.
+func Each(ctx context.Context, conn db.DB, q Query, fn func(district string, ta TruckAnalytics) error) (err error) {
+	segment, ok := segments.Get(q.Segment)
+	if !ok {
+		return fmt.Errorf("unknown segment %q", q.Segment)
+	}
+	brands := q.brands(segment)
+
+	start := time.Now()
+	defer func() { metrics.ObserveQuery(q.Name(), time.Since(start), err) }()
+
+	rows, err := conn.Query(ctx, reportQuery, q.Dataset, segment.Name, brands, q.Months)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *TruckAnalytics
+	var currentDistrict string
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		return fn(currentDistrict, *current)
+	}
+
+	// Rows come ordered by district and region, one per brand
+	for rows.Next() {
+		var federalDistrict, regionName, brand string
+		var regionCode *string
+		var quantity int
+
+		if err := rows.Scan(&federalDistrict, &regionName, &regionCode, &brand, &quantity); err != nil {
+			return err
+		}
+
+		if current == nil || federalDistrict != currentDistrict || regionName != current.RegionName {
+			if err := flush(); err != nil {
+				return err
+			}
+			current = &TruckAnalytics{
+				RegionName: regionName,
+				RegionCode: deref(regionCode),
+				Brands:     make([]BrandVolume, len(brands)),
+			}
+			currentDistrict = federalDistrict
+			for i, b := range brands {
+				current.Brands[i].Brand = b
+			}
+		}
+
+		for i := range current.Brands {
+			if current.Brands[i].Brand == brand {
+				current.Brands[i].Quantity = &quantity
+				current.Total += quantity
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+const monthlyQuery = `
+	SELECT "Month_of_registration", "Brand", SUM("Quantity")::bigint
+	FROM ` + db.AggregatesView + `
+	WHERE
+		"Dataset" = $1
+		AND "Segment" = $2
+		AND "Brand" = ANY($3)
+		AND ($4 = 0 OR "Month_of_registration" <= $4)
+	GROUP BY 1, 2
+	ORDER BY 1
+`
+
+// MonthlyVolumes are registrations of each brand per month, from the first
+// to the last month with registrations. Rows with a month outside 1-12,
+// which the quality checker reports, are left out.
+type MonthlyVolumes struct {
+	Months []int
+	Brands []string
+	// Volumes[i][j] is Brands[i] in Months[j]
+	Volumes [][]int
+}
+
+// Monthly runs a segment report broken down by month instead of region
+func Monthly(ctx context.Context, conn db.DB, q Query) (_ MonthlyVolumes, err error) {
+	segment, ok := segments.Get(q.Segment)
+	if !ok {
+		return MonthlyVolumes{}, fmt.Errorf("unknown segment %q", q.Segment)
+	}
+	brands := q.brands(segment)
+
+	start := time.Now()
+	defer func() { metrics.ObserveQuery(q.Name()+"/monthly", time.Since(start), err) }()
+
+	rows, err := conn.Query(ctx, monthlyQuery, q.Dataset, segment.Name, brands, q.Months)
+	if err != nil {
+		return MonthlyVolumes{}, err
+	}
+	defer rows.Close()
+
+	byMonth := map[int]map[string]int{}
+	first, last := 13, 0
+	for rows.Next() {
+		var month, quantity int
+		var brand string
+		if err := rows.Scan(&month, &brand, &quantity); err != nil {
+			return MonthlyVolumes{}, err
+		}
+		if month < 1 || month > 12 {
+			continue
+		}
+		if byMonth[month] == nil {
+			byMonth[month] = map[string]int{}
+		}
+		byMonth[month][brand] = quantity
+		first, last = min(first, month), max(last, month)
+	}
+	if err := rows.Err(); err != nil {
+		return MonthlyVolumes{}, err
+	}
+
+	result := MonthlyVolumes{Brands: brands, Volumes: make([][]int, len(brands))}
+	if len(byMonth) == 0 {
+		return result, nil
+	}
+	for m := first; m <= last; m++ {
+		result.Months = append(result.Months, m)
+		for i, b := range brands {
+			result.Volumes[i] = append(result.Volumes[i], byMonth[m][b])
+		}
+	}
+	return result, nil
+}
+
+// Table lays a report out for CSV and XLSX exports, one row per region
+// followed by the district totals
+func Table(q Query, data map[string][]TruckAnalytics) exports.Table {
+	t := exports.Table{Name: q.Segment, Header: []string{"federal_district", "region_name", "region_code"}}
+
+	districts := make([]string, 0, len(data))
+	for district := range data {
+		districts = append(districts, district)
+	}
+	slices.Sort(districts)
+
+	for _, district := range districts {
+		for _, row := range data[district] {
+			if len(t.Header) == 3 {
+				for _, b := range row.Brands {
+					t.Header = append(t.Header, BrandKey(b.Brand))
+				}
+				t.Header = append(t.Header, "total")
+			}
+
+			cells := []any{district, row.RegionName, row.RegionCode}
+			for _, b := range row.Brands {
+				if b.Quantity == nil {
+					cells = append(cells, nil)
+				} else {
+					cells = append(cells, *b.Quantity)
+				}
+			}
+			t.Rows = append(t.Rows, append(cells, row.Total))
+		}
+	}
+	return t
+}
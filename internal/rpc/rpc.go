@@ -0,0 +1,297 @@
+// Package rpc serves the segment reports over gRPC for internal services.
+// It runs the same report queries and permission checks as the HTTP API.
+//
+// The protobuf code in pb is generated from proto/truckanalytics/v1:
+//
+//	protoc -I proto --go_out=. --go_opt=module=truck-analytics-platform \
+//		--go-grpc_out=. --go-grpc_opt=module=truck-analytics-platform \
+//		truckanalytics/v1/analytics.proto
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/logging"
+	"truck-analytics-platform/internal/metrics"
+	"truck-analytics-platform/internal/ratelimit"
+	"truck-analytics-platform/internal/rpc/pb"
+	"truck-analytics-platform/internal/tracing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type Config struct {
+	// Addr is the listen address, empty disables the server
+	Addr string
+	// MaxConcurrentStreams limits the calls in flight per connection
+	MaxConcurrentStreams uint32
+	Auth                 auth.Config
+	// Guard applies rate limits and query slots, shared with the HTTP API
+	Guard *ratelimit.Guard
+}
+
+// ConfigFromEnv reads GRPC_ADDR and GRPC_MAX_CONCURRENT_STREAMS, along with
+// the API key settings and rate limits of the HTTP API
+func ConfigFromEnv() Config {
+	cfg := Config{Addr: ":9090", MaxConcurrentStreams: 16, Auth: auth.ConfigFromEnv(), Guard: ratelimit.Default()}
+	if addr, ok := os.LookupEnv("GRPC_ADDR"); ok {
+		cfg.Addr = addr
+	}
+	if n, err := strconv.Atoi(os.Getenv("GRPC_MAX_CONCURRENT_STREAMS")); err == nil && n > 0 {
+		cfg.MaxConcurrentStreams = uint32(n)
+	}
+	return cfg
+}
+
+// NewServer registers the analytics service and server reflection
+func NewServer(cfg Config) *grpc.Server {
+	a := authenticator{cfg: cfg.Auth}
+	l := limiter{guard: cfg.Guard}
+	server := grpc.NewServer(
+		grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams),
+		grpc.ChainUnaryInterceptor(unaryLogger, a.unary, l.unary),
+		grpc.ChainStreamInterceptor(streamLogger, a.stream, l.stream),
+	)
+	pb.RegisterSegmentAnalyticsServer(server, &service{})
+	reflection.Register(server)
+	return server
+}
+
+// Serve listens on the configured address until the server stops
+func Serve(cfg Config) error {
+	if cfg.Addr == "" {
+		return nil
+	}
+	lis, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("gRPC server started", "addr", cfg.Addr)
+	return NewServer(cfg).Serve(lis)
+}
+
+type keyContextKey struct{}
+
+// permissions returns what the key of a call may query. Without
+// authentication everything is allowed, as over HTTP.
+func permissions(ctx context.Context) auth.Permissions {
+	key, _ := ctx.Value(keyContextKey{}).(auth.Key)
+	return key.Permissions
+}
+
+type authenticator struct {
+	cfg auth.Config
+}
+
+func (a authenticator) unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, contextStream{ss, ctx})
+}
+
+// authenticate checks the key in the "authorization: Bearer" or "x-api-key"
+// metadata and stores it in the context
+func (a authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	if !a.cfg.Enabled {
+		return ctx, nil
+	}
+
+	secret := keyFromMetadata(ctx)
+	if secret == "" {
+		return ctx, status.Error(codes.Unauthenticated, "API key required")
+	}
+	if a.cfg.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.cfg.AdminKey)) == 1 {
+		return context.WithValue(ctx, keyContextKey{}, auth.Key{Name: "bootstrap", Permissions: auth.Permissions{Admin: true}}), nil
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		slog.WarnContext(ctx, "Can't connect to database")
+		return ctx, status.Error(codes.Unavailable, "Database is unavailable")
+	}
+	key, err := auth.Lookup(tracing.WithOperation(ctx, "api key lookup"), conn, secret)
+	if errors.Is(err, auth.ErrNotFound) {
+		return ctx, status.Error(codes.Unauthenticated, "Invalid API key")
+	}
+	if err != nil {
+		return ctx, status.Error(codes.Internal, "Failed to check API key: "+err.Error())
+	}
+	return context.WithValue(ctx, keyContextKey{}, key), nil
+}
+
+// heavyMethods run report queries. They get the heavy limit and take a
+// query slot, other methods get the default limit.
+var heavyMethods = []string{
+	pb.SegmentAnalytics_GetReport_FullMethodName,
+	pb.SegmentAnalytics_StreamReport_FullMethodName,
+	pb.SegmentAnalytics_GetTimeSeries_FullMethodName,
+	pb.SegmentAnalytics_CompareYears_FullMethodName,
+}
+
+// limiter limits calls like the HTTP API limits routes, per API key or per
+// address without one. Limits are kept per method.
+type limiter struct {
+	guard *ratelimit.Guard
+}
+
+func (l limiter) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if l.guard == nil {
+		return handler(ctx, req)
+	}
+	release, err := l.admit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+func (l limiter) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if l.guard == nil {
+		return handler(srv, ss)
+	}
+	release, err := l.admit(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handler(srv, ss)
+}
+
+// admit takes a token for the call and, for heavy methods, a query slot
+// held until release is called
+func (l limiter) admit(ctx context.Context, method string) (release func(), err error) {
+	heavy := slices.Contains(heavyMethods, method)
+	if delay, ok := l.guard.Take(method, caller(ctx), heavy); !ok {
+		return nil, rateLimited(method, "rate", delay)
+	}
+	if !heavy {
+		return func() {}, nil
+	}
+	release, ok := l.guard.Acquire(ctx)
+	if !ok {
+		return nil, rateLimited(method, "concurrency", time.Second)
+	}
+	return release, nil
+}
+
+// caller identifies the client of a call by API key, or by address
+// without one
+func caller(ctx context.Context) string {
+	if key, ok := ctx.Value(keyContextKey{}).(auth.Key); ok {
+		return ratelimit.KeyClient(key)
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "ip:"
+}
+
+func rateLimited(method, reason string, retryAfter time.Duration) error {
+	metrics.ObserveRateLimited(method, reason)
+	return status.Errorf(codes.ResourceExhausted, "Too many requests, retry in %ds", ratelimit.RetrySeconds(retryAfter))
+}
+
+func keyFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if key := md.Get("x-api-key"); len(key) > 0 && key[0] != "" {
+		return key[0]
+	}
+	for _, v := range md.Get("authorization") {
+		if bearer, ok := strings.CutPrefix(v, "Bearer "); ok {
+			return strings.TrimSpace(bearer)
+		}
+	}
+	return ""
+}
+
+// withRequestID takes the request ID from the "x-request-id" metadata or
+// assigns one, and sends it back in the header
+func withRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if ids := md.Get("x-request-id"); len(ids) > 0 {
+		id = ids[0]
+	}
+	id = logging.UsableRequestID(id)
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	return logging.WithRequestID(ctx, id)
+}
+
+func unaryLogger(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx = withRequestID(tracing.WithOperation(ctx, info.FullMethod))
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamLogger(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := withRequestID(tracing.WithOperation(ss.Context(), info.FullMethod))
+	err := handler(srv, contextStream{ss, ctx})
+	logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// logCall writes one access log line per call, like the HTTP middleware
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []any{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "rpc", attrs...)
+}
+
+// contextStream replaces the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
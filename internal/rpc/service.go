@@ -0,0 +1,305 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"truck-analytics-platform/internal/brands"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/geo"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/rpc/pb"
+	"truck-analytics-platform/internal/segments"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type service struct {
+	pb.UnimplementedSegmentAnalyticsServer
+}
+
+func connect(ctx context.Context) (db.DB, error) {
+	conn, err := db.Connect()
+	if err != nil {
+		slog.WarnContext(ctx, "Can't connect to database")
+		return nil, status.Error(codes.Unavailable, "Database is unavailable")
+	}
+	return conn, nil
+}
+
+func queryFailed(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, "Failed to execute query: "+err.Error())
+}
+
+func (s *service) ListSegments(ctx context.Context, _ *pb.ListSegmentsRequest) (*pb.ListSegmentsResponse, error) {
+	p := permissions(ctx)
+	resp := &pb.ListSegmentsResponse{}
+	for _, segment := range segments.All {
+		if len(p.Segments) > 0 && !slices.Contains(p.Segments, segment.Name) {
+			continue
+		}
+		resp.Segments = append(resp.Segments, &pb.Segment{
+			Name:         segment.Name,
+			WheelFormula: segment.WheelFormula,
+			BodyType:     segment.BodyType,
+			Brands:       p.AllowedBrands(segment.Brands),
+		})
+	}
+	return resp, nil
+}
+
+func (s *service) ListPeriods(ctx context.Context, _ *pb.ListPeriodsRequest) (*pb.ListPeriodsResponse, error) {
+	conn, err := connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return nil, queryFailed(err)
+	}
+
+	p := permissions(ctx)
+	resp := &pb.ListPeriodsResponse{}
+	for _, d := range datasets {
+		if len(p.Years) > 0 && !slices.Contains(p.Years, d.Year) {
+			continue
+		}
+		resp.Periods = append(resp.Periods, &pb.Period{
+			Dataset:   d.Table,
+			Year:      int32(d.Year),
+			FromMonth: int32(d.FromMonth),
+			ToMonth:   int32(d.ToMonth),
+		})
+	}
+	return resp, nil
+}
+
+func (s *service) GetReport(ctx context.Context, req *pb.ReportRequest) (*pb.Report, error) {
+	conn, err := connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := resolve(ctx, conn, req)
+	if err != nil {
+		return nil, err
+	}
+	return r.run(ctx, conn)
+}
+
+func (s *service) StreamReport(req *pb.ReportRequest, stream grpc.ServerStreamingServer[pb.RegionVolume]) error {
+	ctx := stream.Context()
+	conn, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	r, _, err := resolve(ctx, conn, req)
+	if err != nil {
+		return err
+	}
+	// Rows are sent as they are read, a failed send ends the query
+	var sendErr error
+	err = reports.Each(ctx, conn, r.Query, func(district string, ta reports.TruckAnalytics) error {
+		sendErr = stream.Send(regionVolume(district, ta))
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return queryFailed(err)
+	}
+	return nil
+}
+
+func (s *service) GetTimeSeries(ctx context.Context, req *pb.ReportRequest) (*pb.TimeSeries, error) {
+	conn, err := connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := resolve(ctx, conn, req)
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := reports.Monthly(ctx, conn, r.Query)
+	if err != nil {
+		return nil, queryFailed(err)
+	}
+
+	resp := &pb.TimeSeries{Dataset: r.Dataset.Table, Segment: r.Segment}
+	for _, m := range monthly.Months {
+		resp.Months = append(resp.Months, int32(m))
+	}
+	for i, brand := range monthly.Brands {
+		series := &pb.BrandSeries{Brand: brand}
+		for _, v := range monthly.Volumes[i] {
+			series.Volumes = append(series.Volumes, int64(v))
+		}
+		resp.Series = append(resp.Series, series)
+	}
+	return resp, nil
+}
+
+func (s *service) CompareYears(ctx context.Context, req *pb.ReportRequest) (*pb.YearComparison, error) {
+	conn, err := connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current, datasets, err := resolve(ctx, conn, req)
+	if err != nil {
+		return nil, err
+	}
+	currentData, err := reports.Run(ctx, conn, current.Query)
+	if err != nil {
+		return nil, queryFailed(err)
+	}
+	resp := &pb.YearComparison{Current: current.message(currentData)}
+
+	var previousData map[string][]reports.TruckAnalytics
+	q, ok := current.PreviousYear(datasets)
+	if ok && permissions(ctx).AllowsReport(current.Segment, current.Dataset.Year-1) {
+		if previousData, err = reports.Run(ctx, conn, q); err != nil {
+			return nil, queryFailed(err)
+		}
+		d, _ := db.ParseDataset(q.Dataset)
+		resp.Previous = report{Query: q, Dataset: d}.message(previousData)
+	}
+
+	comparison := reports.Compare(currentData, previousData)
+	for _, brand := range current.Brands {
+		resp.Brands = append(resp.Brands, change(brand, comparison.Brand(brand)))
+	}
+	resp.Total = change("", comparison.Brand(""))
+	return resp, nil
+}
+
+// report is a resolved report request
+type report struct {
+	reports.Query
+	Dataset db.Dataset
+}
+
+// resolve picks the dataset of a request and the brands the key may see.
+// The datasets loaded are returned for finding comparison periods.
+func resolve(ctx context.Context, conn db.DB, req *pb.ReportRequest) (report, []db.Dataset, error) {
+	segment, ok := segments.Get(req.Segment)
+	if !ok {
+		return report{}, nil, status.Errorf(codes.InvalidArgument, "unknown segment %q", req.Segment)
+	}
+	if req.Months < 0 || req.Months > 12 {
+		return report{}, nil, status.Error(codes.InvalidArgument, "months must be between 0 and 12")
+	}
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return report{}, nil, queryFailed(err)
+	}
+	dataset, err := db.SelectDataset(datasets, req.Dataset, int(req.Year), int(req.Months))
+	if err != nil {
+		return report{}, nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	p := permissions(ctx)
+	names := segment.Brands
+	if len(req.Brands) > 0 {
+		if names, err = brands.Canonical(ctx, conn, req.Brands); err != nil {
+			return report{}, nil, queryFailed(err)
+		}
+	}
+	names = p.AllowedBrands(names)
+	if !p.AllowsReport(segment.Name, dataset.Year) || len(names) == 0 {
+		return report{}, nil, status.Error(codes.PermissionDenied, "API key has no access to this report")
+	}
+
+	q := reports.Query{Dataset: dataset.Table, Segment: segment.Name, Months: int(req.Months), Brands: names}
+	return report{Query: q, Dataset: dataset}, datasets, nil
+}
+
+// run executes the report
+func (r report) run(ctx context.Context, conn db.DB) (*pb.Report, error) {
+	data, err := reports.Run(ctx, conn, r.Query)
+	if err != nil {
+		return nil, queryFailed(err)
+	}
+	return r.message(data), nil
+}
+
+// message lays out the data of the report
+func (r report) message(data map[string][]reports.TruckAnalytics) *pb.Report {
+	resp := &pb.Report{
+		Dataset: r.Dataset.Table,
+		Segment: r.Segment,
+		Year:    int32(r.Dataset.Year),
+		ToMonth: int32(r.Dataset.ToMonth),
+		Title:   r.Title(),
+		Brands:  r.Brands,
+		Regions: regionVolumes(data),
+	}
+	if r.Months > 0 && r.Months < r.Dataset.ToMonth {
+		resp.ToMonth = int32(r.Months)
+	}
+	for _, row := range resp.Regions {
+		if row.DistrictTotal {
+			resp.Total += row.Total
+		}
+	}
+	return resp
+}
+
+// regionVolumes lists report rows by district, each district followed by
+// its totals
+func regionVolumes(data map[string][]reports.TruckAnalytics) []*pb.RegionVolume {
+	districts := make([]string, 0, len(data))
+	for district := range data {
+		districts = append(districts, district)
+	}
+	slices.Sort(districts)
+
+	var rows []*pb.RegionVolume
+	for _, district := range districts {
+		for _, ta := range data[district] {
+			rows = append(rows, regionVolume(district, ta))
+		}
+	}
+	return rows
+}
+
+func regionVolume(district string, ta reports.TruckAnalytics) *pb.RegionVolume {
+	d, _ := geo.FindDistrict(district)
+	row := &pb.RegionVolume{
+		District:      district,
+		DistrictCode:  d.Code,
+		Region:        ta.RegionName,
+		RegionCode:    ta.RegionCode,
+		DistrictTotal: ta.RegionName == district,
+		Total:         int64(ta.Total),
+	}
+	for _, b := range ta.Brands {
+		v := &pb.BrandVolume{Brand: b.Brand}
+		if b.Quantity != nil {
+			q := int64(*b.Quantity)
+			v.Quantity = &q
+		}
+		row.Brands = append(row.Brands, v)
+	}
+	return row
+}
+
+// change is the message of a brand's change, the empty brand being the
+// report total
+func change(brand string, c reports.Change) *pb.Change {
+	resp := &pb.Change{Brand: brand, Current: int64(c.Current)}
+	if c.Previous == nil {
+		return resp
+	}
+	resp.Previous = int64(*c.Previous)
+	if delta, ok := c.Delta(); ok {
+		percent := delta * 100
+		resp.Percent = &percent
+	}
+	return resp
+}
@@ -0,0 +1,151 @@
+package saved
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Dashboard is an ordered list of saved definitions
+type Dashboard struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name" binding:"required"`
+	Description string    `json:"description"`
+	Definitions []int64   `json:"definitions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const dashboardColumns = `id, name, description,
+	ARRAY(SELECT definition_id FROM dashboard_items WHERE dashboard_id = dashboards.id ORDER BY position),
+	created_at, updated_at`
+
+func scanDashboard(row pgx.Row) (Dashboard, error) {
+	var d Dashboard
+	err := row.Scan(&d.ID, &d.Name, &d.Description, &d.Definitions, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrNotFound
+	}
+	return d, constraintError(err)
+}
+
+func ListDashboards(ctx context.Context, conn db.DB, o Owner) ([]Dashboard, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT `+dashboardColumns+` FROM dashboards
+		WHERE $1::bigint IS NULL OR owner_key_id = $1
+		ORDER BY name, id
+	`, o.filter())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Dashboard{}
+	for rows.Next() {
+		d, err := scanDashboard(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+func GetDashboard(ctx context.Context, conn db.DB, o Owner, id int64) (Dashboard, error) {
+	return scanDashboard(conn.QueryRow(ctx, `
+		SELECT `+dashboardColumns+` FROM dashboards
+		WHERE id = $1 AND ($2::bigint IS NULL OR owner_key_id = $2)
+	`, id, o.filter()))
+}
+
+func CreateDashboard(ctx context.Context, conn db.DB, o Owner, d Dashboard) (Dashboard, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `INSERT INTO dashboards (owner_key_id, name, description) VALUES ($1, $2, $3) RETURNING id`,
+		o.Key, d.Name, d.Description).Scan(&id)
+	if err != nil {
+		return d, constraintError(err)
+	}
+	if err := setItems(ctx, tx, o, id, d.Definitions); err != nil {
+		return d, err
+	}
+
+	created, err := GetDashboard(ctx, tx, Owner{All: true}, id)
+	if err != nil {
+		return created, err
+	}
+	return created, tx.Commit(ctx)
+}
+
+func UpdateDashboard(ctx context.Context, conn db.DB, o Owner, id int64, d Dashboard) (Dashboard, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE dashboards SET name = $3, description = $4, updated_at = now()
+		WHERE id = $1 AND ($2::bigint IS NULL OR owner_key_id = $2)
+	`, id, o.filter(), d.Name, d.Description)
+	if err != nil {
+		return d, constraintError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return d, ErrNotFound
+	}
+	if err := setItems(ctx, tx, o, id, d.Definitions); err != nil {
+		return d, err
+	}
+
+	updated, err := GetDashboard(ctx, tx, Owner{All: true}, id)
+	if err != nil {
+		return updated, err
+	}
+	return updated, tx.Commit(ctx)
+}
+
+func DeleteDashboard(ctx context.Context, conn db.DB, o Owner, id int64) error {
+	tag, err := conn.Exec(ctx, `DELETE FROM dashboards WHERE id = $1 AND ($2::bigint IS NULL OR owner_key_id = $2)`, id, o.filter())
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// setItems replaces the definitions of a dashboard. They have to be
+// definitions the owner can see.
+func setItems(ctx context.Context, tx pgx.Tx, o Owner, id int64, definitions []int64) error {
+	var missing []int64
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(DISTINCT i), '{}') FROM unnest($1::bigint[]) AS i
+		WHERE NOT EXISTS (
+			SELECT 1 FROM report_definitions d
+			WHERE d.id = i AND ($2::bigint IS NULL OR d.owner_key_id = $2)
+		)
+	`, definitions, o.filter()).Scan(&missing)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: definitions %v", ErrNotFound, missing)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM dashboard_items WHERE dashboard_id = $1`, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO dashboard_items (dashboard_id, position, definition_id)
+		SELECT $1, position, definition_id FROM unnest($2::bigint[]) WITH ORDINALITY AS t(definition_id, position)
+	`, id, definitions)
+	return err
+}
@@ -0,0 +1,172 @@
+// Package saved stores named report definitions and dashboards composed of
+// them. Items belong to the API key that created them.
+package saved
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/segments"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Comparisons a definition can ask for
+const (
+	CompareNone         = ""
+	ComparePreviousYear = "previous_year"
+)
+
+// Formats a definition runs in
+var Formats = []string{reports.FormatJSON, reports.FormatGeoJSON, exports.FormatCSV, exports.FormatXLSX}
+
+var (
+	ErrNotFound = errors.New("saved item not found")
+	ErrConflict = errors.New("name already in use")
+)
+
+// Owner is the API key a request acts for, nil for the bootstrap key and
+// without authentication. Items are created for Key. All lets admins and
+// unauthenticated requests see every item.
+type Owner struct {
+	Key *int64
+	All bool
+}
+
+// filter is the owner_key_id to limit queries to, nil for no limit
+func (o Owner) filter() *int64 {
+	if o.All {
+		return nil
+	}
+	return o.Key
+}
+
+// Definition is a saved segment report: the segment, the period, the brand
+// columns, an optional comparison and the output format
+type Definition struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Segment     string `json:"segment" binding:"required"`
+	// Year 0 is the latest year loaded
+	Year int `json:"year"`
+	// Months limits the report to the first months of the year, 0 is every month loaded
+	Months int `json:"months"`
+	// Brands overrides the segment brand columns
+	Brands    []string  `json:"brands"`
+	Compare   string    `json:"compare"`
+	Format    string    `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (d Definition) Validate() error {
+	if _, ok := segments.Get(d.Segment); !ok {
+		return fmt.Errorf("unknown segment %q", d.Segment)
+	}
+	if d.Year < 0 {
+		return errors.New("year must not be negative")
+	}
+	if d.Months < 0 || d.Months > 12 {
+		return errors.New("months must be between 0 and 12")
+	}
+	if d.Compare != CompareNone && d.Compare != ComparePreviousYear {
+		return fmt.Errorf("unknown comparison %q", d.Compare)
+	}
+	if !slices.Contains(Formats, d.Format) {
+		return fmt.Errorf("unknown format %q", d.Format)
+	}
+	if d.Compare != CompareNone && d.Format == reports.FormatGeoJSON {
+		return errors.New("geojson can't hold a comparison")
+	}
+	return nil
+}
+
+const definitionColumns = `id, name, description, segment, year, months, brands, compare, format, created_at, updated_at`
+
+func scanDefinition(row pgx.Row) (Definition, error) {
+	var d Definition
+	err := row.Scan(&d.ID, &d.Name, &d.Description, &d.Segment, &d.Year, &d.Months, &d.Brands,
+		&d.Compare, &d.Format, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrNotFound
+	}
+	return d, constraintError(err)
+}
+
+func ListDefinitions(ctx context.Context, conn db.DB, o Owner) ([]Definition, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT `+definitionColumns+` FROM report_definitions
+		WHERE $1::bigint IS NULL OR owner_key_id = $1
+		ORDER BY name, id
+	`, o.filter())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Definition{}
+	for rows.Next() {
+		d, err := scanDefinition(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+func GetDefinition(ctx context.Context, conn db.DB, o Owner, id int64) (Definition, error) {
+	return scanDefinition(conn.QueryRow(ctx, `
+		SELECT `+definitionColumns+` FROM report_definitions
+		WHERE id = $1 AND ($2::bigint IS NULL OR owner_key_id = $2)
+	`, id, o.filter()))
+}
+
+func CreateDefinition(ctx context.Context, conn db.DB, o Owner, d Definition) (Definition, error) {
+	return scanDefinition(conn.QueryRow(ctx, `
+		INSERT INTO report_definitions (owner_key_id, name, description, segment, year, months, brands, compare, format)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+definitionColumns,
+		o.Key, d.Name, d.Description, d.Segment, d.Year, d.Months, nonNil(d.Brands), d.Compare, d.Format))
+}
+
+func UpdateDefinition(ctx context.Context, conn db.DB, o Owner, id int64, d Definition) (Definition, error) {
+	return scanDefinition(conn.QueryRow(ctx, `
+		UPDATE report_definitions
+		SET name = $3, description = $4, segment = $5, year = $6, months = $7, brands = $8, compare = $9, format = $10,
+			updated_at = now()
+		WHERE id = $1 AND ($2::bigint IS NULL OR owner_key_id = $2)
+		RETURNING `+definitionColumns,
+		id, o.filter(), d.Name, d.Description, d.Segment, d.Year, d.Months, nonNil(d.Brands), d.Compare, d.Format))
+}
+
+// DeleteDefinition removes a definition, and it from the dashboards
+func DeleteDefinition(ctx context.Context, conn db.DB, o Owner, id int64) error {
+	tag, err := conn.Exec(ctx, `DELETE FROM report_definitions WHERE id = $1 AND ($2::bigint IS NULL OR owner_key_id = $2)`, id, o.filter())
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func constraintError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package saved
+
+import (
+	"context"
+	"errors"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/reports"
+	"truck-analytics-platform/internal/segments"
+)
+
+var (
+	ErrNoDataset = errors.New("no registration data for the period")
+	ErrNoAccess  = errors.New("API key has no access to this report")
+)
+
+// Report is a report run by a definition
+type Report struct {
+	Query reports.Query                       `json:"report"`
+	Data  map[string][]reports.TruckAnalytics `json:"data"`
+}
+
+// Result is the outcome of a definition. Previous holds the same months of
+// the year before when the definition compares with it and they are loaded.
+type Result struct {
+	Definition Definition `json:"definition"`
+	Report
+	Previous *Report `json:"previous"`
+}
+
+// Tables lays the result out for CSV and XLSX
+func (r Result) Tables() []exports.Table {
+	tables := []exports.Table{reports.Table(r.Query, r.Data)}
+	if r.Previous != nil {
+		tables = append(tables, reports.Table(r.Previous.Query, r.Previous.Data))
+	}
+	return tables
+}
+
+// Run executes a definition with the segments, years and brands the
+// permissions allow
+func Run(ctx context.Context, conn db.DB, d Definition, p auth.Permissions) (Result, error) {
+	result := Result{Definition: d}
+
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return result, err
+	}
+	year := d.Year
+	if year == 0 && len(datasets) > 0 {
+		year = datasets[len(datasets)-1].Year
+	}
+	current, ok := db.PickDataset(datasets, year, d.Months)
+	if !ok {
+		return result, ErrNoDataset
+	}
+
+	segment, _ := segments.Get(d.Segment)
+	brands := d.Brands
+	if len(brands) == 0 {
+		brands = segment.Brands
+	}
+	brands = p.AllowedBrands(brands)
+	if !p.AllowsReport(d.Segment, year) || len(brands) == 0 {
+		return result, ErrNoAccess
+	}
+
+	result.Query = reports.Query{Dataset: current.Table, Segment: d.Segment, Months: d.Months, Brands: brands}
+	if result.Data, err = reports.Run(ctx, conn, result.Query); err != nil {
+		return result, err
+	}
+
+	if d.Compare != ComparePreviousYear || !p.AllowsReport(d.Segment, year-1) {
+		return result, nil
+	}
+	previous, ok := result.Query.PreviousYear(datasets)
+	if !ok {
+		return result, nil
+	}
+	result.Previous = &Report{Query: previous}
+	result.Previous.Data, err = reports.Run(ctx, conn, previous)
+	return result, err
+}
+
+// Panel is one definition of a dashboard run. Error tells why it has no
+// result, e.g. missing data or permissions.
+type Panel struct {
+	*Result
+	Error string `json:"error,omitempty"`
+}
+
+// DashboardResult is a dashboard with the results of its definitions, in order
+type DashboardResult struct {
+	Dashboard Dashboard `json:"dashboard"`
+	Panels    []Panel   `json:"panels"`
+}
+
+// RunDashboard runs every definition of a dashboard. Definitions that can't
+// run are reported in their panel instead of failing the dashboard.
+func RunDashboard(ctx context.Context, conn db.DB, o Owner, id int64, p auth.Permissions) (DashboardResult, error) {
+	dashboard, err := GetDashboard(ctx, conn, o, id)
+	if err != nil {
+		return DashboardResult{}, err
+	}
+	result := DashboardResult{Dashboard: dashboard, Panels: []Panel{}}
+
+	for _, definitionID := range dashboard.Definitions {
+		d, err := GetDefinition(ctx, conn, Owner{All: true}, definitionID)
+		if err != nil {
+			return result, err
+		}
+		r, err := Run(ctx, conn, d, p)
+		switch {
+		case errors.Is(err, ErrNoDataset) || errors.Is(err, ErrNoAccess):
+			result.Panels = append(result.Panels, Panel{Result: &Result{Definition: d}, Error: err.Error()})
+		case err != nil:
+			return result, err
+		default:
+			result.Panels = append(result.Panels, Panel{Result: &r})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SMTPConfig is the mail server outputs are sent through. Username may be
+// empty for servers without authentication, like local test servers.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// deliver sends an output wherever the job asks and returns where it went
+func deliver(cfg Config, j Job, out Output, now time.Time) (string, error) {
+	var targets []string
+
+	if j.Delivery.Directory != "" {
+		dir := filepath.Join(cfg.OutputDir, j.Delivery.Directory)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+		path := filepath.Join(dir, now.UTC().Format("20060102T150405")+"-"+out.Name)
+		if err := os.WriteFile(path, out.Data, 0o644); err != nil {
+			return "", err
+		}
+		targets = append(targets, path)
+	}
+
+	if len(j.Delivery.Email) > 0 {
+		if err := sendMail(cfg.SMTP, j.Delivery.Email, out, now); err != nil {
+			return strings.Join(targets, ", "), err
+		}
+		targets = append(targets, "email to "+strings.Join(j.Delivery.Email, ", "))
+	}
+
+	return strings.Join(targets, ", "), nil
+}
+
+func sendMail(cfg SMTPConfig, to []string, out Output, now time.Time) error {
+	if cfg.Host == "" {
+		return fmt.Errorf("SMTP_HOST is not set")
+	}
+	message, err := mailMessage(cfg.From, to, out, now)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(cfg.Host, cfg.Port), auth, cfg.From, to, message)
+}
+
+// mailMessage builds a MIME message with the output attached
+func mailMessage(from string, to []string, out Output, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	text, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}, "Content-Transfer-Encoding": {"8bit"}})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(text, "%s\r\n\r\nThe report is attached: %s\r\n", out.Subject, out.Name)
+
+	attachment, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {out.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": out.Name})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(out.Data)
+	for len(encoded) > 76 {
+		attachment.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	attachment.Write([]byte(encoded + "\r\n"))
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", out.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", w.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"bufio"
+	"encoding/base64"
+	"mime"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpStub is a mail server that accepts everything and keeps the envelope
+// and data of each message
+type smtpStub struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	from     string
+	rcpt     []string
+	messages []string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{listener: lis}
+	t.Cleanup(func() { lis.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStub) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPConfig{Host: host, Port: port, From: "reports@example.com"}
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *smtpStub) session(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 stub ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.TrimSpace(line[len("MAIL FROM:"):])
+			s.mu.Unlock()
+			reply("250 ok")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, strings.TrimSpace(line[len("RCPT TO:"):]))
+			s.mu.Unlock()
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func testOutput() Output {
+	return Output{
+		Name:        "tractors4x2.csv",
+		ContentType: "text/csv",
+		Subject:     "Tractors 4x2, 2024",
+		Data:        []byte("region,total\nМосква,10\n"),
+	}
+}
+
+func TestDeliverEmail(t *testing.T) {
+	stub := newSMTPStub(t)
+	j := withDefaults(Job{Delivery: Delivery{Email: []string{"Fleet Team <fleet@example.com>", "ops@example.com"}}})
+	now := time.Date(2024, 10, 1, 6, 0, 0, 0, time.UTC)
+
+	target, err := deliver(Config{SMTP: stub.config()}, j, testOutput(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "email to fleet@example.com, ops@example.com"; target != want {
+		t.Errorf("target = %q, want %q", target, want)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.from != "<reports@example.com>" {
+		t.Errorf("MAIL FROM = %q", stub.from)
+	}
+	if got := strings.Join(stub.rcpt, " "); got != "<fleet@example.com> <ops@example.com>" {
+		t.Errorf("RCPT TO = %q", got)
+	}
+	if len(stub.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(stub.messages))
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(stub.messages[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("To"); got != "fleet@example.com, ops@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Tractors 4x2, 2024" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(testOutput().Data)
+	if !strings.Contains(stub.messages[0], encoded) {
+		t.Error("message doesn't carry the attachment")
+	}
+	if !strings.Contains(stub.messages[0], `filename=tractors4x2.csv`) {
+		t.Error("attachment has no file name")
+	}
+}
+
+func TestDeliverEmailWithoutHost(t *testing.T) {
+	j := withDefaults(Job{Delivery: Delivery{Email: []string{"ops@example.com"}}})
+	if _, err := deliver(Config{}, j, testOutput(), time.Now()); err == nil {
+		t.Error("delivery without SMTP_HOST succeeded")
+	}
+}
+
+func TestDeliverDirectory(t *testing.T) {
+	dir := t.TempDir()
+	j := withDefaults(Job{Delivery: Delivery{Directory: "weekly/"}})
+	now := time.Date(2024, 10, 1, 6, 0, 0, 0, time.UTC)
+
+	target, err := deliver(Config{OutputDir: dir}, j, testOutput(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "weekly", "20241001T060000-tractors4x2.csv")
+	if target != want {
+		t.Errorf("target = %q, want %q", target, want)
+	}
+	data, err := os.ReadFile(want)
+	if err != nil || string(data) != string(testOutput().Data) {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	j := Job{Name: "weekly", Schedule: "0 6 * * 1", Report: ReportBrief, Format: FormatPDF}
+	for address, ok := range map[string]bool{
+		"ops@example.com":                true,
+		"Fleet Team <fleet@example.com>": true,
+		"not an address":                 false,
+	} {
+		j.Delivery.Email = []string{address}
+		if err := j.Validate(); (err == nil) != ok {
+			t.Errorf("Validate(%q) = %v", address, err)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/segments"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/robfig/cron/v3"
+)
+
+// Reports a job can produce
+const (
+	// ReportBrief is the market brief of a year, see briefs.Build
+	ReportBrief = "brief"
+	// ReportSegment is a single segment report
+	ReportSegment = "segment"
+
+	FormatPDF = "pdf"
+)
+
+var ErrNotFound = errors.New("job not found")
+
+// Params select the data of a report. Year 0 and an empty Dataset mean the
+// latest loaded year, Months 0 every loaded month.
+type Params struct {
+	Year    int    `json:"year,omitempty"`
+	Months  int    `json:"months,omitempty"`
+	Dataset string `json:"dataset,omitempty"`
+	Segment string `json:"segment,omitempty"`
+}
+
+// Delivery lists where outputs go, by email and/or into a directory under
+// the configured output directory
+type Delivery struct {
+	Email     []string `json:"email"`
+	Directory string   `json:"directory"`
+}
+
+type Job struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name" binding:"required"`
+	// Schedule is a 5 field cron expression in UTC
+	Schedule    string     `json:"schedule"`
+	OnNewData   bool       `json:"on_new_data"`
+	Report      string     `json:"report" binding:"required"`
+	Params      Params     `json:"params"`
+	Format      string     `json:"format" binding:"required"`
+	Delivery    Delivery   `json:"delivery"`
+	MaxAttempts int        `json:"max_attempts"`
+	Enabled     bool       `json:"enabled"`
+	NextRunAt   *time.Time `json:"next_run_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type Run struct {
+	ID         int64      `json:"id"`
+	JobID      int64      `json:"job_id"`
+	Trigger    string     `json:"trigger"`
+	Attempt    int        `json:"attempt"`
+	Status     string     `json:"status"`
+	Error      *string    `json:"error"`
+	Output     *string    `json:"output"`
+	SizeBytes  *int       `json:"size_bytes"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	RetryAt    *time.Time `json:"retry_at"`
+}
+
+// Run triggers and statuses
+const (
+	TriggerSchedule = "schedule"
+	TriggerData     = "data"
+	TriggerManual   = "manual"
+	TriggerRetry    = "retry"
+
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Validate checks a job before it's stored
+func (j Job) Validate() error {
+	if j.Schedule != "" {
+		if _, err := cron.ParseStandard(j.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
+	if j.Schedule == "" && !j.OnNewData {
+		return errors.New("job needs a schedule or on_new_data")
+	}
+
+	switch j.Report {
+	case ReportBrief:
+		if !slices.Contains([]string{FormatPDF, exports.FormatCSV, exports.FormatXLSX}, j.Format) {
+			return errors.New("brief format must be pdf, csv or xlsx")
+		}
+	case ReportSegment:
+		if _, ok := segments.Get(j.Params.Segment); !ok {
+			return fmt.Errorf("unknown segment %q", j.Params.Segment)
+		}
+		if j.Params.Dataset != "" {
+			if _, ok := db.ParseDataset(j.Params.Dataset); !ok {
+				return fmt.Errorf("invalid dataset %q", j.Params.Dataset)
+			}
+		}
+		if !slices.Contains([]string{exports.FormatCSV, exports.FormatXLSX}, j.Format) {
+			return errors.New("segment report format must be csv or xlsx")
+		}
+	default:
+		return fmt.Errorf("unknown report %q", j.Report)
+	}
+	if j.Params.Months < 0 || j.Params.Months > 12 {
+		return errors.New("months must be between 1 and 12, or 0 for every loaded month")
+	}
+
+	if len(j.Delivery.Email) == 0 && j.Delivery.Directory == "" {
+		return errors.New("job needs an email or directory delivery")
+	}
+	for _, address := range j.Delivery.Email {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("invalid email %q", address)
+		}
+	}
+	if d := j.Delivery.Directory; d != "" && (filepath.IsAbs(d) || !filepath.IsLocal(d)) {
+		return errors.New("directory must be relative to the output directory")
+	}
+	if j.MaxAttempts < 0 || j.MaxAttempts > 10 {
+		return errors.New("max_attempts must be between 1 and 10, or 0 for the default of 3")
+	}
+	return nil
+}
+
+// nextRun is when a scheduled job runs next, nil without a schedule
+func (j Job) nextRun(after time.Time) *time.Time {
+	if j.Schedule == "" || !j.Enabled {
+		return nil
+	}
+	schedule, err := cron.ParseStandard(j.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := schedule.Next(after.UTC())
+	return &next
+}
+
+const jobColumns = `id, name, schedule, on_new_data, report, params, format, email, directory, max_attempts, enabled, next_run_at, created_at, updated_at`
+
+func scanJob(row pgx.Row) (Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Name, &j.Schedule, &j.OnNewData, &j.Report, &j.Params, &j.Format, &j.Delivery.Email, &j.Delivery.Directory,
+		&j.MaxAttempts, &j.Enabled, &j.NextRunAt, &j.CreatedAt, &j.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return j, ErrNotFound
+	}
+	return j, err
+}
+
+func List(ctx context.Context, conn db.DB) ([]Job, error) {
+	rows, err := conn.Query(ctx, `SELECT `+jobColumns+` FROM report_jobs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func Get(ctx context.Context, conn db.DB, id int64) (Job, error) {
+	return scanJob(conn.QueryRow(ctx, `SELECT `+jobColumns+` FROM report_jobs WHERE id = $1`, id))
+}
+
+// Create stores a job. Jobs run on new data start from the data loaded now.
+func Create(ctx context.Context, conn db.DB, j Job) (Job, error) {
+	signature, err := dataSignature(ctx, conn)
+	if err != nil {
+		return Job{}, err
+	}
+	j = withDefaults(j)
+
+	return scanJob(conn.QueryRow(ctx, `
+		INSERT INTO report_jobs (name, schedule, on_new_data, report, params, format, email, directory, max_attempts, enabled, next_run_at, data_signature)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING `+jobColumns,
+		j.Name, j.Schedule, j.OnNewData, j.Report, j.Params, j.Format, j.Delivery.Email, j.Delivery.Directory,
+		j.MaxAttempts, j.Enabled, j.nextRun(time.Now()), signature))
+}
+
+func Update(ctx context.Context, conn db.DB, id int64, j Job) (Job, error) {
+	j = withDefaults(j)
+
+	return scanJob(conn.QueryRow(ctx, `
+		UPDATE report_jobs SET
+			name = $2, schedule = $3, on_new_data = $4, report = $5, params = $6, format = $7, email = $8,
+			directory = $9, max_attempts = $10, enabled = $11, next_run_at = $12, updated_at = now()
+		WHERE id = $1
+		RETURNING `+jobColumns,
+		id, j.Name, j.Schedule, j.OnNewData, j.Report, j.Params, j.Format, j.Delivery.Email, j.Delivery.Directory,
+		j.MaxAttempts, j.Enabled, j.nextRun(time.Now())))
+}
+
+// Delete removes a job with its history
+func Delete(ctx context.Context, conn db.DB, id int64) error {
+	tag, err := conn.Exec(ctx, `DELETE FROM report_jobs WHERE id = $1`, id)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func withDefaults(j Job) Job {
+	if j.MaxAttempts == 0 {
+		j.MaxAttempts = 3
+	}
+	// Keep the bare addresses, SMTP recipients can't carry display names
+	emails := make([]string, len(j.Delivery.Email))
+	for i, address := range j.Delivery.Email {
+		emails[i] = address
+		if a, err := mail.ParseAddress(address); err == nil {
+			emails[i] = a.Address
+		}
+	}
+	j.Delivery.Email = emails
+	j.Delivery.Directory = filepath.Clean(j.Delivery.Directory)
+	if j.Delivery.Directory == "." {
+		j.Delivery.Directory = ""
+	}
+	return j
+}
+
+const runColumns = `id, job_id, trigger, attempt, status, error, output, size_bytes, started_at, finished_at, retry_at`
+
+func scanRun(row pgx.Row) (Run, error) {
+	var r Run
+	err := row.Scan(&r.ID, &r.JobID, &r.Trigger, &r.Attempt, &r.Status, &r.Error, &r.Output, &r.SizeBytes, &r.StartedAt, &r.FinishedAt, &r.RetryAt)
+	return r, err
+}
+
+// Runs lists the latest attempts of a job, newest first
+func Runs(ctx context.Context, conn db.DB, jobID int64, limit int) ([]Run, error) {
+	if _, err := Get(ctx, conn, jobID); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, `SELECT `+runColumns+` FROM report_job_runs WHERE job_id = $1 ORDER BY started_at DESC, id DESC LIMIT $2`, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// dataSignature identifies the loaded registration tables
+func dataSignature(ctx context.Context, conn db.DB) (string, error) {
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return "", err
+	}
+	tables := make([]string, len(datasets))
+	for i, d := range datasets {
+		tables[i] = d.Table
+	}
+	return strings.Join(tables, ","), nil
+}
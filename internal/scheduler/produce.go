@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"truck-analytics-platform/internal/auth"
+	"truck-analytics-platform/internal/briefs"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/exports"
+	"truck-analytics-platform/internal/reports"
+)
+
+// Output is a rendered report ready for delivery
+type Output struct {
+	Name        string
+	ContentType string
+	Subject     string
+	Data        []byte
+}
+
+// Produce renders the report of a job
+func Produce(ctx context.Context, conn db.DB, j Job) (Output, error) {
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil {
+		return Output{}, err
+	}
+	if len(datasets) == 0 {
+		return Output{}, briefs.ErrNoDataset
+	}
+	latest := datasets[len(datasets)-1]
+
+	switch j.Report {
+	case ReportBrief:
+		year := j.Params.Year
+		if year == 0 {
+			year = latest.Year
+		}
+		brief, err := briefs.Build(ctx, conn, year, j.Params.Months, auth.Permissions{})
+		if err != nil {
+			return Output{}, err
+		}
+
+		out := Output{
+			Name:    fmt.Sprintf("brief-%d-%02d.%s", brief.Year, brief.Months, j.Format),
+			Subject: "Truck market brief, " + briefs.Period(brief.Year, brief.Months),
+		}
+		if j.Format == FormatPDF {
+			out.ContentType = "application/pdf"
+			out.Data, err = brief.PDF()
+			return out, err
+		}
+		out.ContentType, out.Data, err = exports.Encode(j.Format, brief.Tables())
+		return out, err
+
+	case ReportSegment:
+		q := reports.Query{Dataset: j.Params.Dataset, Segment: j.Params.Segment, Months: j.Params.Months}
+		if q.Dataset == "" {
+			q.Dataset = latest.Table
+		}
+		data, err := reports.Run(ctx, conn, q)
+		if err != nil {
+			return Output{}, err
+		}
+
+		d, _ := db.ParseDataset(q.Dataset)
+		months := d.ToMonth
+		if q.Months > 0 && q.Months < months {
+			months = q.Months
+		}
+		out := Output{
+			Name:    fmt.Sprintf("%s-%d-%02d.%s", q.Segment, d.Year, months, j.Format),
+			Subject: q.Title(),
+		}
+		out.ContentType, out.Data, err = exports.Encode(j.Format, []exports.Table{reports.Table(q, data)})
+		return out, err
+	}
+
+	return Output{}, fmt.Errorf("unknown report %q", j.Report)
+}
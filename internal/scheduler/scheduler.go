@@ -0,0 +1,336 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/webhooks"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type Config struct {
+	Enabled bool
+	// Interval between checks for due jobs, retries and new data
+	Interval time.Duration
+	// RetryDelay is the wait before the first retry, doubled on each attempt
+	RetryDelay time.Duration
+	// Timeout of a single attempt
+	Timeout time.Duration
+	// Workers is the number of jobs executed at once
+	Workers int
+	// OutputDir is where directory deliveries are written
+	OutputDir string
+	SMTP      SMTPConfig
+	// Webhook callbacks are detected and sent on the scheduler's ticks
+	Webhooks webhooks.Config
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Enabled:    os.Getenv("SCHEDULER_DISABLED") != "true",
+		Interval:   30 * time.Second,
+		RetryDelay: time.Minute,
+		Timeout:    10 * time.Minute,
+		Workers:    2,
+		OutputDir:  "reports",
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     "25",
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     "reports@localhost",
+		},
+		Webhooks: webhooks.ConfigFromEnv(),
+	}
+	if s, err := strconv.Atoi(os.Getenv("SCHEDULER_INTERVAL_SECONDS")); err == nil && s > 0 {
+		cfg.Interval = time.Duration(s) * time.Second
+	}
+	if s, err := strconv.Atoi(os.Getenv("JOB_RETRY_DELAY_SECONDS")); err == nil && s > 0 {
+		cfg.RetryDelay = time.Duration(s) * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && n > 0 {
+		cfg.Workers = n
+	}
+	if dir := os.Getenv("REPORT_OUTPUT_DIR"); dir != "" {
+		cfg.OutputDir = dir
+	}
+	if port := os.Getenv("SMTP_PORT"); port != "" {
+		cfg.SMTP.Port = port
+	}
+	if from := os.Getenv("SMTP_FROM"); from != "" {
+		cfg.SMTP.From = from
+	}
+	return cfg
+}
+
+// Scheduler runs report jobs and sends webhook callbacks. Due jobs,
+// retries and deliveries are claimed with SELECT ... FOR UPDATE SKIP LOCKED,
+// so several app instances can run it. Jobs execute in the background so a
+// slow one doesn't hold up the checks.
+type Scheduler struct {
+	cfg     Config
+	workers chan struct{}
+}
+
+func New(cfg Config) *Scheduler {
+	return &Scheduler{cfg: cfg, workers: make(chan struct{}, max(cfg.Workers, 1))}
+}
+
+// Start checks for work every Interval until ctx is done
+func (s *Scheduler) Start(ctx context.Context, conn db.DB) {
+	if !s.cfg.Enabled {
+		slog.Info("Scheduler disabled, this instance doesn't detect events or send webhooks")
+		return
+	}
+	slog.Info("Scheduler started", "interval", s.cfg.Interval.String())
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		s.tick(ctx, conn)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, conn db.DB) {
+	if err := s.checkData(ctx, conn); err != nil {
+		slog.Error("Can't check for new data", "error", err)
+	}
+	if err := webhooks.Detect(ctx, conn); err != nil {
+		slog.Error("Can't check for webhook events", "error", err)
+	}
+	if err := webhooks.Deliver(ctx, conn, s.cfg.Webhooks); err != nil {
+		slog.Error("Can't deliver webhooks", "error", err)
+	}
+	if err := s.runDue(ctx, conn); err != nil {
+		slog.Error("Can't run scheduled jobs", "error", err)
+	}
+	if err := s.runRetries(ctx, conn); err != nil {
+		slog.Error("Can't retry jobs", "error", err)
+	}
+}
+
+// checkData refreshes the aggregates when registration tables were added or
+// removed, then runs the jobs waiting for new data
+func (s *Scheduler) checkData(ctx context.Context, conn db.DB) error {
+	datasets, err := db.Datasets(ctx, conn)
+	if err != nil || len(datasets) == 0 {
+		return err
+	}
+	current, err := db.AggregatesCurrent(ctx, conn, datasets)
+	if err != nil {
+		return err
+	}
+	if !current {
+		slog.Info("New registration data, refreshing aggregates")
+		if err := db.RefreshAggregates(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	signature, err := dataSignature(ctx, conn)
+	if err != nil {
+		return err
+	}
+	// Claiming a job records the signature, so each change runs it once
+	rows, err := conn.Query(ctx, `
+		UPDATE report_jobs SET data_signature = $1
+		WHERE enabled AND on_new_data AND data_signature IS DISTINCT FROM $1
+		RETURNING `+jobColumns, signature)
+	if err != nil {
+		return err
+	}
+	jobs, err := collectJobs(rows)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		s.run(ctx, conn, j, TriggerData, 1)
+	}
+	return nil
+}
+
+func (s *Scheduler) runDue(ctx context.Context, conn db.DB) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+jobColumns+` FROM report_jobs
+		WHERE enabled AND next_run_at <= now()
+		FOR UPDATE SKIP LOCKED
+	`)
+	if err != nil {
+		return err
+	}
+	jobs, err := collectJobs(rows)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, j := range jobs {
+		if _, err := tx.Exec(ctx, `UPDATE report_jobs SET next_run_at = $2 WHERE id = $1`, j.ID, j.nextRun(now)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, j := range jobs {
+		s.run(ctx, conn, j, TriggerSchedule, 1)
+	}
+	return nil
+}
+
+func (s *Scheduler) runRetries(ctx context.Context, conn db.DB) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE report_job_runs SET retry_at = NULL
+		WHERE id IN (
+			SELECT id FROM report_job_runs
+			WHERE retry_at <= now()
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job_id, attempt
+	`)
+	if err != nil {
+		return err
+	}
+	type retry struct {
+		jobID   int64
+		attempt int
+	}
+	var retries []retry
+	for rows.Next() {
+		var r retry
+		if err := rows.Scan(&r.jobID, &r.attempt); err != nil {
+			return err
+		}
+		retries = append(retries, r)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, r := range retries {
+		j, err := Get(ctx, conn, r.jobID)
+		if err != nil {
+			slog.Error("Can't load job to retry", "job", r.jobID, "error", err)
+			continue
+		}
+		s.run(ctx, conn, j, TriggerRetry, r.attempt+1)
+	}
+	return nil
+}
+
+// Trigger starts a job by hand and returns its run without waiting for it
+func (s *Scheduler) Trigger(ctx context.Context, conn db.DB, id int64) (Run, error) {
+	j, err := Get(ctx, conn, id)
+	if err != nil {
+		return Run{}, err
+	}
+	r, err := s.start(ctx, conn, j, TriggerManual, 1)
+	if err != nil {
+		return Run{}, err
+	}
+	go s.work(context.WithoutCancel(ctx), conn, j, r)
+	return r, nil
+}
+
+// run records a run of a job and executes it in the background
+func (s *Scheduler) run(ctx context.Context, conn db.DB, j Job, trigger string, attempt int) {
+	r, err := s.start(ctx, conn, j, trigger, attempt)
+	if err != nil {
+		slog.Error("Can't start job", "job", j.ID, "error", err)
+		return
+	}
+	go s.work(ctx, conn, j, r)
+}
+
+// work executes a run once a worker is free
+func (s *Scheduler) work(ctx context.Context, conn db.DB, j Job, r Run) {
+	s.workers <- struct{}{}
+	defer func() { <-s.workers }()
+	s.execute(ctx, conn, j, r)
+}
+
+func (s *Scheduler) start(ctx context.Context, conn db.DB, j Job, trigger string, attempt int) (Run, error) {
+	return scanRun(conn.QueryRow(ctx, `
+		INSERT INTO report_job_runs (job_id, trigger, attempt, status) VALUES ($1, $2, $3, $4)
+		RETURNING `+runColumns, j.ID, trigger, attempt, StatusRunning))
+}
+
+// execute produces and delivers a report and records the outcome. Failed
+// attempts are retried with exponential backoff up to MaxAttempts.
+func (s *Scheduler) execute(ctx context.Context, conn db.DB, j Job, r Run) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+
+	log := slog.With("job", j.ID, "name", j.Name, "run", r.ID, "attempt", r.Attempt)
+	start := time.Now()
+
+	out, err := Produce(ctx, conn, j)
+	var target string
+	if err == nil {
+		target, err = deliver(s.cfg, j, out, start)
+	}
+
+	if err == nil {
+		log.Info("Job succeeded", "output", target, "bytes", len(out.Data), "duration_ms", time.Since(start).Milliseconds())
+		_, err = conn.Exec(context.WithoutCancel(ctx), `
+			UPDATE report_job_runs SET status = $2, output = $3, size_bytes = $4, finished_at = now() WHERE id = $1
+		`, r.ID, StatusSucceeded, target, len(out.Data))
+		if err != nil {
+			log.Error("Can't record job run", "error", err)
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if r.Attempt < j.MaxAttempts {
+		at := time.Now().Add(s.cfg.RetryDelay << (r.Attempt - 1))
+		retryAt = &at
+	}
+	log.Error("Job failed", "error", err, "retry_at", retryAt)
+	_, err = conn.Exec(context.WithoutCancel(ctx), `
+		UPDATE report_job_runs SET status = $2, error = $3, finished_at = now(), retry_at = $4 WHERE id = $1
+	`, r.ID, StatusFailed, err.Error(), retryAt)
+	if err != nil {
+		log.Error("Can't record job run", "error", err)
+	}
+}
+
+func collectJobs(rows pgx.Rows) ([]Job, error) {
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,79 @@
+package segments
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter is a single equality condition on a column of the registration tables
+type Filter struct {
+	Column string `json:"column"`
+	Value  any    `json:"value"`
+}
+
+// Segment describes which registrations belong to a market segment and
+// which brands are reported for it
+type Segment struct {
+	Name         string   `json:"name"`
+	WheelFormula string   `json:"wheel_formula"`
+	BodyType     string   `json:"body_type"`
+	Mass         Filter   `json:"mass"`
+	Brands       []string `json:"brands"`
+}
+
+var All = []Segment{
+	{
+		Name:         "tractors4x2",
+		WheelFormula: "4x2",
+		BodyType:     "Седельный тягач",
+		Mass:         Filter{Column: "Exact_mass", Value: 18000},
+		Brands:       []string{"DONGFENG", "FAW", "FOTON", "JAC", "SHACMAN", "SITRAK"},
+	},
+	{
+		Name:         "tractors6x4",
+		WheelFormula: "6x4",
+		BodyType:     "Седельный тягач",
+		Mass:         Filter{Column: "Exact_mass", Value: 25000},
+		Brands:       []string{"DONGFENG", "FAW", "FOTON", "HOWO", "SHACMAN", "SITRAK"},
+	},
+	{
+		Name:         "dumpers6x4",
+		WheelFormula: "6x4",
+		BodyType:     "Самосвал",
+		Mass:         Filter{Column: "Mass_in_segment_1", Value: "32001-40000"},
+		Brands:       []string{"FAW", "HOWO", "JAC", "SANY", "SITRAK"},
+	},
+	{
+		Name:         "dumpers8x4",
+		WheelFormula: "8x4",
+		BodyType:     "Самосвал",
+		Mass:         Filter{Column: "Weight_in_segment_4", Value: "35001-45000"},
+		Brands:       []string{"FAW", "HOWO", "SHACMAN", "SITRAK"},
+	},
+}
+
+func Get(name string) (Segment, bool) {
+	for _, s := range All {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Segment{}, false
+}
+
+// Predicate renders the segment conditions as a SQL boolean expression.
+// Values come from the definitions above, never from requests.
+func (s Segment) Predicate() string {
+	return fmt.Sprintf(`"Wheel_formula" = %s AND "Body_type" = %s AND %q = %s`,
+		Literal(s.WheelFormula), Literal(s.BodyType), s.Mass.Column, Literal(s.Mass.Value))
+}
+
+// Literal renders a constant as a SQL literal
+func Literal(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,315 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Callbacks carry these headers. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret.
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type Config struct {
+	// MaxAttempts before a delivery is given up
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry, doubled on each attempt
+	RetryDelay time.Duration
+	// Timeout of a single callback
+	Timeout time.Duration
+	// Concurrency is the number of callbacks sent at once
+	Concurrency int
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{
+		MaxAttempts: 8,
+		RetryDelay:  30 * time.Second,
+		Timeout:     10 * time.Second,
+		Concurrency: 8,
+	}
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && n > 0 {
+		cfg.MaxAttempts = n
+	}
+	if s, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_DELAY_SECONDS")); err == nil && s > 0 {
+		cfg.RetryDelay = time.Duration(s) * time.Second
+	}
+	if s, err := strconv.Atoi(os.Getenv("WEBHOOK_TIMEOUT_SECONDS")); err == nil && s > 0 {
+		cfg.Timeout = time.Duration(s) * time.Second
+	}
+	return cfg
+}
+
+// Event is the body of a callback
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Delivery is an event sent, or to be sent, to a subscription
+type Delivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	Event          Event      `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	ResponseStatus *int       `json:"response_status"`
+	Error          *string    `json:"error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+const deliveryColumns = `d.id, d.subscription_id, e.id, e.type, e.created_at, e.data, d.status, d.attempts,
+	d.next_attempt_at, d.response_status, d.error, d.created_at, d.delivered_at`
+
+func scanDelivery(row pgx.Row) (Delivery, error) {
+	var d Delivery
+	err := row.Scan(&d.ID, &d.SubscriptionID, &d.Event.ID, &d.Event.Type, &d.Event.CreatedAt, &d.Event.Data,
+		&d.Status, &d.Attempts, &d.NextAttemptAt, &d.ResponseStatus, &d.Error, &d.CreatedAt, &d.DeliveredAt)
+	return d, err
+}
+
+// Deliveries lists the latest deliveries of a subscription, newest first
+func Deliveries(ctx context.Context, conn db.DB, id int64, limit int) ([]Delivery, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN webhook_events e ON e.id = d.event_id
+		WHERE d.subscription_id = $1
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+// target is a claimed delivery with what's needed to send it
+type target struct {
+	Delivery
+	url    string
+	secret string
+}
+
+// Deliver sends the pending deliveries that are due. Claimed deliveries are
+// leased for twice the timeout, so another instance retries them if this one
+// dies mid-send.
+func Deliver(ctx context.Context, conn db.DB, cfg Config) error {
+	rows, err := conn.Query(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $1)
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d
+				JOIN webhook_subscriptions s ON s.id = d.subscription_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND s.enabled
+				ORDER BY d.next_attempt_at
+				LIMIT 100
+				FOR UPDATE OF d SKIP LOCKED
+			)
+			RETURNING *
+		)
+		SELECT `+deliveryColumns+`, s.url, s.secret
+		FROM claimed d
+		JOIN webhook_events e ON e.id = d.event_id
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+	`, 2*cfg.Timeout.Seconds())
+	if err != nil {
+		return err
+	}
+
+	var targets []target
+	for rows.Next() {
+		var t target
+		err := rows.Scan(&t.ID, &t.SubscriptionID, &t.Event.ID, &t.Event.Type, &t.Event.CreatedAt, &t.Event.Data,
+			&t.Status, &t.Attempts, &t.NextAttemptAt, &t.ResponseStatus, &t.Error, &t.CreatedAt, &t.DeliveredAt,
+			&t.url, &t.secret)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(cfg.Concurrency, 1))
+	for _, t := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			if _, err := attempt(ctx, conn, cfg, t); err != nil {
+				slog.Error("Can't record webhook delivery", "delivery", t.ID, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Test sends a ping event to a subscription right away, once, and returns
+// the delivery with its outcome
+func Test(ctx context.Context, conn db.DB, cfg Config, id int64) (Delivery, error) {
+	var t target
+	err := conn.QueryRow(ctx, `SELECT url, secret FROM webhook_subscriptions WHERE id = $1`, id).Scan(&t.url, &t.secret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Delivery{}, ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	data, _ := json.Marshal(map[string]any{"subscription_id": id})
+	t.Delivery, err = scanDelivery(conn.QueryRow(ctx, `
+		WITH e AS (
+			INSERT INTO webhook_events (type, data) VALUES ($1, $2) RETURNING *
+		), d AS (
+			INSERT INTO webhook_deliveries (event_id, subscription_id, attempts, next_attempt_at)
+			SELECT e.id, $3, 1, NULL FROM e
+			RETURNING *
+		)
+		SELECT `+deliveryColumns+` FROM d, e
+	`, EventPing, data, id))
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	cfg.MaxAttempts = 1
+	return attempt(ctx, conn, cfg, t)
+}
+
+// attempt sends one callback and records the outcome, scheduling a retry
+// with exponential backoff when it failed and attempts are left
+func attempt(ctx context.Context, conn db.DB, cfg Config, t target) (Delivery, error) {
+	log := slog.With("delivery", t.ID, "subscription", t.SubscriptionID, "event", t.Event.Type, "attempt", t.Attempts)
+
+	code, err := send(ctx, cfg, t)
+	now := time.Now()
+	d := t.Delivery
+	if code != 0 {
+		d.ResponseStatus = &code
+	}
+	d.NextAttemptAt = nil
+	d.Error = nil
+
+	switch {
+	case err == nil:
+		log.Info("Webhook delivered", "status", code)
+		d.Status = StatusSucceeded
+		d.DeliveredAt = &now
+	case d.Attempts < cfg.MaxAttempts:
+		retryAt := now.Add(cfg.RetryDelay << (d.Attempts - 1))
+		log.Warn("Webhook failed", "error", err, "retry_at", retryAt)
+		d.Status = StatusPending
+		d.NextAttemptAt = &retryAt
+	default:
+		log.Error("Webhook failed, giving up", "error", err)
+		d.Status = StatusFailed
+	}
+	if err != nil {
+		message := err.Error()
+		d.Error = &message
+	}
+
+	_, err = conn.Exec(context.WithoutCancel(ctx), `
+		UPDATE webhook_deliveries
+		SET status = $2, next_attempt_at = $3, response_status = $4, error = $5, delivered_at = $6
+		WHERE id = $1
+	`, d.ID, d.Status, d.NextAttemptAt, d.ResponseStatus, d.Error, d.DeliveredAt)
+	return d, err
+}
+
+// send posts the event and returns the response status. Anything but 2xx
+// is an error.
+func send(ctx context.Context, cfg Config, t target) (int, error) {
+	body, err := json.Marshal(t.Event)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "truck-analytics-webhooks")
+	req.Header.Set(HeaderID, t.Event.ID)
+	req.Header.Set(HeaderEvent, t.Event.Type)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(t.secret, timestamp, body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the signature receivers should compare with the
+// X-Webhook-Signature header
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
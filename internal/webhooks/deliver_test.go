@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// recorder is a db.DB that keeps the arguments of each Exec, which is all
+// attempt needs to record an outcome
+type recorder struct {
+	db.DB
+
+	mu    sync.Mutex
+	execs [][]any
+}
+
+func (r *recorder) Exec(_ context.Context, _ string, args ...any) (pgconn.CommandTag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.execs = append(r.execs, args)
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func testTarget(url string) target {
+	return target{
+		Delivery: Delivery{
+			ID:             7,
+			SubscriptionID: 3,
+			Event: Event{
+				ID:        "0b6c1c52-4c1e-4a59-9d43-7a1f1f0c2d11",
+				Type:      EventPing,
+				CreatedAt: time.Date(2024, 10, 1, 6, 0, 0, 0, time.UTC),
+				Data:      map[string]any{"subscription_id": 3},
+			},
+			Status: StatusPending,
+		},
+		url:    url,
+		secret: "s3cret",
+	}
+}
+
+func TestAttemptSigns(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	conn := &recorder{}
+	tg := testTarget(server.URL)
+	tg.Attempts = 1
+	d, err := attempt(context.Background(), conn, Config{MaxAttempts: 3, RetryDelay: time.Second, Timeout: time.Second}, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != StatusSucceeded || d.DeliveredAt == nil || d.ResponseStatus == nil || *d.ResponseStatus != http.StatusOK {
+		t.Errorf("delivery = %+v", d)
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil || event.ID != tg.Event.ID {
+		t.Errorf("body = %s, %v", body, err)
+	}
+	if header.Get(HeaderID) != tg.Event.ID || header.Get(HeaderEvent) != EventPing {
+		t.Errorf("headers = %v", header)
+	}
+	want := "sha256=" + Sign("s3cret", header.Get(HeaderTimestamp), body)
+	if got := header.Get(HeaderSignature); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+	if len(conn.execs) != 1 || conn.execs[0][1] != StatusSucceeded {
+		t.Errorf("recorded %v", conn.execs)
+	}
+}
+
+func TestAttemptRetriesThenFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := Config{MaxAttempts: 4, RetryDelay: time.Minute, Timeout: time.Second}
+	conn := &recorder{}
+	tg := testTarget(server.URL)
+
+	for _, tc := range []struct {
+		attempts int
+		status   string
+		delay    time.Duration
+	}{
+		{1, StatusPending, time.Minute},
+		{2, StatusPending, 2 * time.Minute},
+		{3, StatusPending, 4 * time.Minute},
+		{4, StatusFailed, 0},
+	} {
+		// Deliver counts the attempt when it claims the delivery
+		tg.Attempts = tc.attempts
+		before := time.Now()
+		d, err := attempt(context.Background(), conn, cfg, tg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status != tc.status {
+			t.Errorf("attempt %d: status = %s, want %s", tc.attempts, d.Status, tc.status)
+		}
+		if d.ResponseStatus == nil || *d.ResponseStatus != http.StatusServiceUnavailable || d.Error == nil {
+			t.Errorf("attempt %d: outcome not recorded: %+v", tc.attempts, d)
+		}
+		switch {
+		case tc.delay == 0 && d.NextAttemptAt != nil:
+			t.Errorf("attempt %d: retry scheduled at %v after giving up", tc.attempts, d.NextAttemptAt)
+		case tc.delay > 0 && d.NextAttemptAt == nil:
+			t.Errorf("attempt %d: no retry scheduled", tc.attempts)
+		case tc.delay > 0:
+			if wait := d.NextAttemptAt.Sub(before); wait < tc.delay || wait > tc.delay+time.Second {
+				t.Errorf("attempt %d: retry in %v, want %v", tc.attempts, wait, tc.delay)
+			}
+		}
+		tg.Delivery = d
+	}
+
+	if len(conn.execs) != 4 || conn.execs[3][1] != StatusFailed {
+		t.Errorf("recorded %v", conn.execs)
+	}
+}
@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"truck-analytics-platform/internal/db"
+	"truck-analytics-platform/internal/notify"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReportChanged is the data of a report.changed event. PreviousTotal is the
+// number of registrations in the report before the change.
+type ReportChanged struct {
+	Dataset       string `json:"dataset"`
+	Segment       string `json:"segment"`
+	Total         int64  `json:"total"`
+	PreviousTotal int64  `json:"previous_total"`
+}
+
+// Detect records events for registration tables that appeared and for
+// reports whose numbers changed since the last call. It compares against
+// the aggregates, so it should run after they are refreshed.
+func Detect(ctx context.Context, conn db.DB) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one app instance looks for changes at a time, so each change is
+	// announced once
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('webhooks.detect'))`).Scan(&locked); err != nil || !locked {
+		return err
+	}
+
+	datasets, err := db.Datasets(ctx, tx)
+	if err != nil || len(datasets) == 0 {
+		return err
+	}
+	if err := detectDatasets(ctx, tx, datasets); err != nil {
+		return err
+	}
+	if err := detectReports(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// detectDatasets announces the dataset.added event with the table and period
+// of each new registration table
+func detectDatasets(ctx context.Context, tx pgx.Tx, datasets []db.Dataset) error {
+
+	for _, d := range datasets {
+		tag, err := tx.Exec(ctx, `INSERT INTO seen_datasets (table_name) VALUES ($1) ON CONFLICT DO NOTHING`, d.Table)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		slog.Info("New dataset", "table", d.Table)
+		if err := Emit(ctx, tx, EventDatasetAdded, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectReports fingerprints every dataset × segment of the aggregates.
+// Reports seen for the first time are recorded without an event, new
+// datasets are announced by dataset.added.
+func detectReports(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, `
+		WITH current AS (
+			SELECT "Dataset" AS dataset, "Segment" AS segment,
+				md5(string_agg(
+					concat_ws('|', "Month_of_registration", "Federal_district", "Region", "Brand", "Quantity"), ','
+					ORDER BY "Month_of_registration", "Federal_district", "Region", "Brand"
+				)) AS checksum,
+				SUM("Quantity")::bigint AS total
+			FROM `+db.AggregatesView+`
+			GROUP BY 1, 2
+		)
+		SELECT c.dataset, c.segment, c.checksum, c.total, r.checksum, r.total
+		FROM current c
+		LEFT JOIN report_checksums r USING (dataset, segment)
+		WHERE r.checksum IS DISTINCT FROM c.checksum
+	`)
+	if err != nil {
+		return err
+	}
+
+	type change struct {
+		ReportChanged
+		checksum string
+		known    bool
+	}
+	var changes []change
+	for rows.Next() {
+		var c change
+		var previous *string
+		var previousTotal *int64
+		if err := rows.Scan(&c.Dataset, &c.Segment, &c.checksum, &c.Total, &previous, &previousTotal); err != nil {
+			rows.Close()
+			return err
+		}
+		if previous != nil {
+			c.known = true
+			c.PreviousTotal = *previousTotal
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO report_checksums (dataset, segment, checksum, total) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (dataset, segment) DO UPDATE SET checksum = excluded.checksum, total = excluded.total, updated_at = now()
+		`, c.Dataset, c.Segment, c.checksum, c.Total)
+		if err != nil {
+			return err
+		}
+		if !c.known {
+			continue
+		}
+		slog.Info("Report changed", "dataset", c.Dataset, "segment", c.Segment, "total", c.Total, "previous_total", c.PreviousTotal)
+		if err := Emit(ctx, tx, EventReportChanged, c.ReportChanged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Emit records an event, queues a delivery to every enabled subscription
+// that wants it and publishes it to live listeners
+func Emit(ctx context.Context, conn db.DB, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	e := notify.Event{Type: event, Data: payload}
+	err = conn.QueryRow(ctx, `INSERT INTO webhook_events (type, data) VALUES ($1, $2) RETURNING id, created_at`,
+		event, payload).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(ctx, `
+		INSERT INTO webhook_deliveries (event_id, subscription_id)
+		SELECT $1::uuid, id FROM webhook_subscriptions
+		WHERE enabled AND (cardinality(events) = 0 OR $2 = ANY (events))
+	`, e.ID, event)
+	if err != nil {
+		return err
+	}
+	return notify.Publish(ctx, conn, e)
+}
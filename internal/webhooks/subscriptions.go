@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+	"truck-analytics-platform/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Event types
+const (
+	EventDatasetAdded  = "dataset.added"
+	EventReportChanged = "report.changed"
+	EventPing          = "ping"
+)
+
+var EventTypes = []string{EventDatasetAdded, EventReportChanged, EventPing}
+
+var ErrNotFound = errors.New("webhook not found")
+
+type Subscription struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url" binding:"required"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreatedSubscription carries the signing secret, which is only shown once
+type CreatedSubscription struct {
+	Subscription
+	Secret string `json:"secret"`
+}
+
+func (s Subscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	for _, e := range s.Events {
+		if !slices.Contains(EventTypes, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	return nil
+}
+
+const subscriptionColumns = `id, url, events, description, enabled, created_at, updated_at`
+
+func scanSubscription(row pgx.Row) (Subscription, error) {
+	var s Subscription
+	err := row.Scan(&s.ID, &s.URL, &s.Events, &s.Description, &s.Enabled, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+func List(ctx context.Context, conn db.DB) ([]Subscription, error) {
+	rows, err := conn.Query(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Subscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+// Create stores a subscription with a new signing secret
+func Create(ctx context.Context, conn db.DB, s Subscription) (CreatedSubscription, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return CreatedSubscription{}, err
+	}
+	secret := "whsec_" + hex.EncodeToString(buf)
+
+	created, err := scanSubscription(conn.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (url, secret, events, description, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+subscriptionColumns,
+		s.URL, secret, nonNil(s.Events), s.Description, s.Enabled))
+	return CreatedSubscription{Subscription: created, Secret: secret}, err
+}
+
+func Update(ctx context.Context, conn db.DB, id int64, s Subscription) (Subscription, error) {
+	return scanSubscription(conn.QueryRow(ctx, `
+		UPDATE webhook_subscriptions SET url = $2, events = $3, description = $4, enabled = $5, updated_at = now()
+		WHERE id = $1
+		RETURNING `+subscriptionColumns,
+		id, s.URL, nonNil(s.Events), s.Description, s.Enabled))
+}
+
+func Delete(ctx context.Context, conn db.DB, id int64) error {
+	tag, err := conn.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err == nil && tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}